@@ -7,11 +7,6 @@ NC='\033[0m'
 
 echo "Installing Darknode CLI..."
 
-# Install unzip if command not found
-if ! [ -x "$(command -v unzip)" ];then
-  sudo apt-get install unzip
-fi
-
 # creating working directory
 mkdir -p $HOME/.darknode/darknodes
 mkdir -p $HOME/.darknode/bin
@@ -23,13 +18,10 @@ cputype="$(uname -m)"
 
 # download darknode binary depending on the system and architecture
 if [ "$ostype" = 'Linux' -a "$cputype" = 'x86_64' ]; then
-    TERRAFORM_URL='https://releases.hashicorp.com/terraform/0.12.24/terraform_0.12.24_linux_amd64.zip'
     curl -sL 'https://www.github.com/renproject/darknode-cli/releases/latest/download/darknode_linux_amd64' > ./bin/darknode
 elif [ "$ostype" = 'Linux' -a "$cputype" = 'aarch64' ]; then
-    TERRAFORM_URL='https://releases.hashicorp.com/terraform/0.12.24/terraform_0.12.24_linux_arm.zip'
     curl -sL 'https://www.github.com/renproject/darknode-cli/releases/latest/download/darknode_linux_arm' > ./bin/darknode
 elif [ "$ostype" = 'Darwin' -a "$cputype" = 'x86_64' ]; then
-    TERRAFORM_URL='https://releases.hashicorp.com/terraform/0.12.24/terraform_0.12.24_darwin_amd64.zip'
     curl -sL 'https://www.github.com/renproject/darknode-cli/releases/latest/download/darknode_darwin_amd64' > ./bin/darknode
 else
    echo 'unsupported OS type or architecture'
@@ -40,14 +32,8 @@ fi
 
 chmod +x bin/darknode
 
-# download terraform
-curl -s "$TERRAFORM_URL" > terraform.zip
-unzip terraform
-chmod +x terraform
-mv terraform bin/terraform
-
-# clean up zip files
-rm terraform.zip
+# download terraform, the version is pinned and verified by the CLI
+./bin/darknode doctor
 
 # make sure the binary is installed in the path
 if ! [ -x "$(command -v darknode)" ]; then
@@ -28,5 +28,8 @@ fi
 
 chmod +x bin/darknode
 
+# Upgrade terraform to the version pinned by the CLI
+./bin/darknode doctor
+
 echo ''
 echo 'Done! Your darknode-cli has been updated.'
\ No newline at end of file
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/renproject/darknode-cli/cmd/provider"
+	"github.com/urfave/cli"
+)
+
+// listRegions prints all the regions of the selected provider.
+func listRegions(ctx *cli.Context) error {
+	p, err := provider.ParseProvider(ctx)
+	if err != nil {
+		return err
+	}
+	regions, err := provider.CatalogRegions(p, ctx.Bool("refresh"))
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		return json.NewEncoder(os.Stdout).Encode(regions)
+	}
+	for _, region := range regions {
+		fmt.Println(region)
+	}
+	return nil
+}
+
+// listSizes prints all the instance types of the selected provider in the given
+// region, with the hardware and price of each instance type.
+func listSizes(ctx *cli.Context) error {
+	region := ctx.String("region")
+	if region == "" {
+		return errors.New("please provide a region, you can find all regions with the `regions` command")
+	}
+	p, err := provider.ParseProvider(ctx)
+	if err != nil {
+		return err
+	}
+	instances, err := provider.CatalogInstanceTypes(p, region, ctx.Bool("refresh"))
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		return json.NewEncoder(os.Stdout).Encode(instances)
+	}
+	fmt.Printf("%-25s | %-5s | %-12s | %-12s | %-12s\n", "name", "vcpu", "memory (GiB)", "hourly", "monthly")
+	for _, instance := range instances {
+		hourly, monthly := "unknown", "unknown"
+		if instance.PriceHourly > 0 {
+			hourly = fmt.Sprintf("%.4f %v", instance.PriceHourly, instance.Currency)
+		}
+		if instance.PriceMonthly > 0 {
+			monthly = fmt.Sprintf("%.2f %v", instance.PriceMonthly, instance.Currency)
+		}
+		fmt.Printf("%-25s | %-5d | %-12.2f | %-12s | %-12s\n", instance.Name, instance.CPUs, instance.Memory, hourly, monthly)
+	}
+	return nil
+}
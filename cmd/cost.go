@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/cmd/provider"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/renproject/phi"
+	"github.com/urfave/cli"
+)
+
+// nodeCost is the estimated monthly cost of a darknode.
+type nodeCost struct {
+	Name         string  `json:"name"`
+	Provider     string  `json:"provider"`
+	Region       string  `json:"region"`
+	Instance     string  `json:"instance"`
+	PriceMonthly float64 `json:"priceMonthly"`
+	Currency     string  `json:"currency"`
+	Known        bool    `json:"known"`
+}
+
+// customPrice is the price of an instance type specified by user in the
+// `pricing.json` file under the .darknode directory. It's used when the price is
+// not available from the provider catalog.
+type customPrice struct {
+	Provider     string  `json:"provider"`
+	Region       string  `json:"region"`
+	Instance     string  `json:"instance"`
+	PriceMonthly float64 `json:"priceMonthly"`
+	Currency     string  `json:"currency"`
+}
+
+// estimateCost reports the estimated monthly cost of each darknode and the total
+// cost. Results can be filtered by the tags.
+func estimateCost(ctx *cli.Context) error {
+	tags := ctx.String("tags")
+	nodesNames, err := util.GetNodesByTags(tags)
+	if err != nil {
+		return err
+	}
+	customPrices, err := readCustomPrices()
+	if err != nil {
+		return err
+	}
+
+	costs := make([]nodeCost, len(nodesNames))
+	phi.ParForAll(nodesNames, func(i int) {
+		name := nodesNames[i]
+		costs[i] = nodeCost{Name: name}
+		p, err := provider.NodeProvider(name)
+		if err != nil {
+			color.Red("[%v] cannot get the provider of the darknode, err = %v", name, err)
+			return
+		}
+		costs[i].Provider = p.Name()
+		region, instance, err := p.Instance(name)
+		if err != nil {
+			color.Red("[%v] cannot get the instance type of the darknode, err = %v", name, err)
+			return
+		}
+		costs[i].Region, costs[i].Instance = region, instance
+		costs[i].PriceMonthly, costs[i].Currency, costs[i].Known = price(p.Name(), region, instance, customPrices)
+	})
+
+	// Fetch the catalog of the regions whose prices are unknown and try again.
+	fetched := fetchCatalogs(ctx, costs)
+	for i, cost := range costs {
+		if !cost.Known && fetched[cost.Provider+"/"+cost.Region] {
+			costs[i].PriceMonthly, costs[i].Currency, costs[i].Known = price(cost.Provider, cost.Region, cost.Instance, customPrices)
+		}
+	}
+
+	if ctx.Bool("json") {
+		return json.NewEncoder(os.Stdout).Encode(costs)
+	}
+
+	// Print the cost of each darknode and the total cost in each currency.
+	totals := map[string]float64{}
+	missing := false
+	fmt.Printf("%-20s | %-8s | %-20s | %-20s | %-15s\n", "name", "provider", "region", "instance", "monthly")
+	for _, cost := range costs {
+		monthly := "unknown"
+		if cost.Known {
+			monthly = fmt.Sprintf("%.2f %v", cost.PriceMonthly, cost.Currency)
+			totals[cost.Currency] += cost.PriceMonthly
+		} else {
+			missing = true
+		}
+		fmt.Printf("%-20s | %-8s | %-20s | %-20s | %-15s\n", cost.Name, cost.Provider, cost.Region, cost.Instance, monthly)
+	}
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		color.Green("Total: %.2f %v per month", totals[currency], currency)
+	}
+	if missing {
+		color.Yellow("Prices of some darknodes are unknown. You can add them to %v", customPricesPath())
+	}
+	return nil
+}
+
+// fetchCatalogs fetches the catalog of each provider and region which has a
+// darknode with an unknown price, using the credentials given to the command.
+// Each provider is only created once and each catalog is only fetched once. It
+// returns the provider and region pairs whose catalog has been fetched.
+func fetchCatalogs(ctx *cli.Context, costs []nodeCost) map[string]bool {
+	providers := map[string]provider.Provider{}
+	fetched := map[string]bool{}
+	for _, cost := range costs {
+		key := cost.Provider + "/" + cost.Region
+		if cost.Known || cost.Region == "" || !hasCatalog(cost.Provider) {
+			continue
+		}
+		if _, ok := fetched[key]; ok {
+			continue
+		}
+		fetched[key] = false
+
+		p, ok := providers[cost.Provider]
+		if !ok {
+			var err error
+			p, err = provider.NewProvider(ctx, cost.Provider)
+			if err != nil {
+				color.Yellow("cannot fetch the prices of %v, err = %v", cost.Provider, err)
+			}
+			providers[cost.Provider] = p
+		}
+		if p == nil {
+			continue
+		}
+		if _, err := provider.CatalogInstanceTypes(p, cost.Region, false); err != nil {
+			color.Yellow("cannot fetch the prices of %v in [%v], err = %v", cost.Provider, cost.Region, err)
+			continue
+		}
+		fetched[key] = true
+	}
+	return fetched
+}
+
+// hasCatalog returns whether the provider has a catalog of instance types.
+func hasCatalog(providerName string) bool {
+	return providerName != provider.NameSsh && providerName != provider.NameDocker
+}
+
+// price returns the monthly price of the instance type from the cached provider
+// catalog or the custom prices, and false if the price is unknown.
+func price(providerName, region, instance string, customPrices []customPrice) (float64, string, bool) {
+	for _, custom := range customPrices {
+		if custom.Provider == providerName && custom.Region == region && custom.Instance == instance {
+			return custom.PriceMonthly, custom.Currency, true
+		}
+	}
+	if cached, ok := provider.CachedInstanceType(providerName, region, instance); ok && cached.PriceMonthly > 0 {
+		return cached.PriceMonthly, cached.Currency, true
+	}
+	return 0, "", false
+}
+
+func customPricesPath() string {
+	return filepath.Join(util.Directory, "pricing.json")
+}
+
+// readCustomPrices reads the custom prices from the `pricing.json` file if exists.
+func readCustomPrices() ([]customPrice, error) {
+	data, err := ioutil.ReadFile(customPricesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var prices []customPrice
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("cannot parse %v, err = %v", customPricesPath(), err)
+	}
+	return prices, nil
+}
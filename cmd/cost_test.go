@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/renproject/darknode-cli/cmd/provider"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/urfave/cli"
+)
+
+func TestFetchCatalogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "darknode-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDirectory := util.Directory
+	util.Directory = dir
+	defer func() {
+		util.Directory = oldDirectory
+		os.RemoveAll(dir)
+	}()
+
+	// The catalog of AWS in eu-west-1 is cached, but hasn't been read.
+	cache := map[string]interface{}{
+		"timestamp":     time.Now(),
+		"instanceTypes": []provider.InstanceType{{Name: "t3.micro", CPUs: 2, Memory: 1, PriceMonthly: 7.59, Currency: "USD"}},
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "cache"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cache", "aws-eu-west-1.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, name := range []string{"aws-access-key", "aws-secret-key", "aws-session-token", "aws-profile", "hetzner-token"} {
+		set.String(name, "", "")
+	}
+	if err := set.Parse([]string{"--aws-access-key", "AKID", "--aws-secret-key", "SECRET"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := cli.NewContext(nil, set, nil)
+
+	costs := []nodeCost{
+		{Name: "a", Provider: "aws", Region: "eu-west-1", Instance: "t3.micro"},
+		{Name: "b", Provider: "aws", Region: "eu-west-1", Instance: "t3.micro"},
+		{Name: "c", Provider: "aws", Region: "us-east-1", Instance: "t3.micro", Known: true},
+		{Name: "d", Provider: "hetzner", Region: "fsn1", Instance: "cx22"},
+		{Name: "e", Provider: "ssh"},
+		{Name: "f", Provider: "aws"},
+	}
+	fetched := fetchCatalogs(ctx, costs)
+	expected := map[string]bool{"aws/eu-west-1": true, "hetzner/fsn1": false}
+	if !reflect.DeepEqual(fetched, expected) {
+		t.Fatalf("expected %v, got %v", expected, fetched)
+	}
+	if monthly, currency, ok := price("aws", "eu-west-1", "t3.micro", nil); !ok || monthly != 7.59 || currency != "USD" {
+		t.Errorf("unexpected price %v %v", monthly, currency)
+	}
+}
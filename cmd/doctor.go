@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os/exec"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/urfave/cli"
+)
+
+// checkTerraform is the Before hook of the commands which run terraform. It
+// refuses to run the command if the terraform managed by the CLI is missing or
+// incompatible.
+func checkTerraform(ctx *cli.Context) error {
+	return util.CheckTerraform()
+}
+
+// doctor checks the tools needed by the CLI and installs the pinned version of
+// terraform if it's missing or incompatible.
+func doctor(ctx *cli.Context) error {
+	if err := util.CheckTerraform(); err != nil {
+		color.Yellow("✗ %v", err)
+		color.Green("Installing terraform v%v ...", util.TerraformVersion)
+		if err := util.InstallTerraform(); err != nil {
+			return err
+		}
+		if err := util.CheckTerraform(); err != nil {
+			return err
+		}
+	}
+	installed, err := util.InstalledTerraformVersion()
+	if err != nil {
+		return err
+	}
+	color.Green("✓ terraform v%v (%v)", installed, util.TerraformPath())
+
+	if _, err := exec.LookPath("ssh"); err != nil {
+		color.Yellow("✗ ssh is not installed, `darknode ssh` will not work")
+	} else {
+		color.Green("✓ ssh")
+	}
+	return nil
+}
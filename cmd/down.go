@@ -16,6 +16,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/cmd/provider"
 	"github.com/renproject/darknode-cli/darknode"
 	"github.com/renproject/darknode-cli/darknode/bindings"
 	"github.com/renproject/darknode-cli/util"
@@ -59,7 +60,23 @@ func destroyNode(ctx *cli.Context) error {
 	if err := util.ValidateNodeName(name); err != nil {
 		return err
 	}
-	path := util.NodePath(name)
+	return destroySingleNode(ctx, name, force)
+}
+
+// destroySingleNode tears down the darknode after checking it has been fully
+// deregistered and refunded, and confirming with the user, unless forced.
+func destroySingleNode(ctx *cli.Context, name string, force bool) error {
+	// A darknode which failed to deploy before terraform created anything can
+	// be removed even if its provider is unknown.
+	hasState := provider.HasState(name)
+	var p provider.Provider
+	if hasState {
+		var err error
+		p, err = provider.ParseNodeProvider(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Check node current registration status.
 	if !force {
@@ -76,7 +93,7 @@ func destroyNode(ctx *cli.Context) error {
 		}
 
 		// Last time confirm with user.
-		fmt.Println("Are you sure you want to destroy your Darknode? (y/N)")
+		fmt.Printf("Are you sure you want to destroy your Darknode [%v]? (y/N)\n", name)
 		reader := bufio.NewReader(os.Stdin)
 		text, _ := reader.ReadString('\n')
 		input := strings.ToLower(strings.TrimSpace(text))
@@ -90,9 +107,13 @@ func destroyNode(ctx *cli.Context) error {
 		return err
 	}
 
-	color.Green("Destroying your Darknode...")
-	destroy := fmt.Sprintf("cd %v && terraform destroy --force && cd .. && rm -rf %v", path, name)
-	return util.Run("bash", "-c", destroy)
+	if hasState {
+		color.Green("Destroying your Darknode...")
+		if err := p.Destroy(name); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(util.NodePath(name))
 }
 
 // Withdraw ETH and REN in the darknode address to the provided receiver address
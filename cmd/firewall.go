@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/cmd/provider"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/renproject/phi"
+	"github.com/urfave/cli"
+)
+
+// allowSSH restricts SSH access of the darknodes to the given CIDR ranges. The
+// ports used by the darknode stay open to anywhere.
+func allowSSH(ctx *cli.Context) error {
+	name := ctx.Args().First()
+	tags := ctx.String("tags")
+	var cidrs []string
+	for _, cidr := range ctx.StringSlice("cidr") {
+		cidrs = append(cidrs, strings.Split(cidr, ",")...)
+	}
+	cidrs, err := provider.ParseCIDRs(cidrs)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := util.ParseNodesFromNameAndTags(name, tags)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(nodes))
+	phi.ParForAll(nodes, func(i int) {
+		p, err := provider.ParseNodeProvider(ctx, nodes[i])
+		if err != nil {
+			errs[i] = err
+			color.Red("[%v] cannot update the firewall: %v", nodes[i], err)
+			return
+		}
+		errs[i] = p.AllowSSH(nodes[i], cidrs)
+		if errs[i] == nil {
+			color.Green("[%v] SSH is only allowed from %v", nodes[i], strings.Join(cidrs, ", "))
+		} else {
+			color.Red("[%v] cannot update the firewall: %v", nodes[i], errs[i])
+		}
+	})
+	return util.HandleErrs(errs)
+}
+
+// showFirewall prints the SSH allow-list and the public ports of the darknodes.
+func showFirewall(ctx *cli.Context) error {
+	name := ctx.Args().First()
+	tags := ctx.String("tags")
+	var nodes []string
+	if name != "" {
+		if err := util.ValidateNodeName(name); err != nil {
+			return err
+		}
+		nodes = []string{name}
+	} else {
+		var err error
+		nodes, err = util.GetNodesByTags(tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%-20s | %-45s | %-15s\n", "name", "ssh allowed from", "public ports")
+	for _, node := range nodes {
+		cidrs, err := provider.SSHAllowList(node)
+		allowed := strings.Join(cidrs, ", ")
+		if err == provider.ErrFirewallNotManaged {
+			allowed += " (not managed, run `darknode migrate` to restrict)"
+		} else if err != nil {
+			color.Red("[%v] cannot read the firewall: %v", node, err)
+			continue
+		}
+		fmt.Printf("%-20s | %-45s | %-15s\n", node, allowed, "18514-18515/tcp")
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/renproject/darknode-cli/cmd/provider"
 	"github.com/urfave/cli"
 )
@@ -9,11 +11,11 @@ import (
 var (
 	NameFlag = cli.StringFlag{
 		Name:  "name",
-		Usage: "A unique human-readable `string` for identifying the Darknode",
+		Usage: "A unique human-readable `string` for identifying the Darknode, or comma separated names for deploying many Darknodes",
 	}
 	TagsFlag = cli.StringFlag{
 		Name:  "tags",
-		Usage: "Multiple human-readable comma separated `strings` for identifying groups of Darknodes",
+		Usage: "Comma separated `tags` for identifying groups of Darknodes, use `|` for alternatives and `!` for tags which must not match",
 	}
 	ScriptFlag = cli.StringFlag{
 		Name:  "script",
@@ -48,6 +50,70 @@ var (
 		Name:  "force, f",
 		Usage: "Force updating to an older version without interactive prompts",
 	}
+	RegionFlag = cli.StringFlag{
+		Name:  "region",
+		Usage: "Region (zone for Google Cloud Platform) of the cloud provider",
+	}
+	JsonFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the output in JSON format",
+	}
+	RefreshFlag = cli.BoolFlag{
+		Name:  "refresh",
+		Usage: "Fetch the latest data from the provider instead of using the local cache",
+	}
+	ImageFlag = cli.StringFlag{
+		Name:  "image",
+		Value: provider.DefaultImage,
+		Usage: "OS `image` of the Darknode (ubuntu-24.04 or ubuntu-22.04)",
+	}
+	StaticIPFlag = cli.BoolFlag{
+		Name:  "static-ip",
+		Usage: "Allocate a static public IP for the Darknode which is kept across resizes (AWS, Digital Ocean and Google Cloud Platform)",
+	}
+	CidrFlag = cli.StringSliceFlag{
+		Name:  "cidr",
+		Usage: "CIDR `range` allowed to reach SSH on the Darknode, can be repeated or comma separated",
+	}
+	SpotFlag = cli.BoolFlag{
+		Name:  "spot",
+		Usage: "Deploy the Darknode on a spot instance, which is cheaper but can be reclaimed (AWS and Google Cloud Platform, not for mainnet)",
+	}
+	IntervalFlag = cli.DurationFlag{
+		Name:  "interval",
+		Value: 5 * time.Minute,
+		Usage: "How often the watchdog checks the Darknodes",
+	}
+	OnceFlag = cli.BoolFlag{
+		Name:  "once",
+		Usage: "Check the Darknodes once and exit",
+	}
+	FailuresFlag = cli.IntFlag{
+		Name:  "failures",
+		Value: 3,
+		Usage: "Number of consecutive failed checks before the watchdog checks the instance of a Darknode with its provider",
+	}
+	FleetFlag = cli.StringFlag{
+		Name:  "file, f",
+		Usage: "Path of the fleet `file` describing all the Darknodes, in YAML or JSON",
+	}
+	CountFlag = cli.IntFlag{
+		Name:  "count",
+		Usage: "`Number` of Darknodes to deploy, named with the name prefix followed by a number",
+	}
+	NamePrefixFlag = cli.StringFlag{
+		Name:  "name-prefix",
+		Usage: "A `string` prefixing the names of the Darknodes deployed with --count",
+	}
+	ConcurrencyFlag = cli.IntFlag{
+		Name:  "concurrency",
+		Value: 4,
+		Usage: "Maximum number of Darknodes deployed at the same time",
+	}
+	IPv6Flag = cli.BoolFlag{
+		Name:  "ipv6",
+		Usage: "Enable IPv6 on the Darknode (AWS, Digital Ocean and Google Cloud Platform)",
+	}
 )
 
 // AWS flags
@@ -57,16 +123,23 @@ var (
 		Usage: "AWS will be used to provision the Darknode",
 	}
 	AwsAccessKeyFlag = cli.StringFlag{
-		Name:  "aws-access-key",
-		Usage: "AWS access `key` for programmatic access",
+		Name:   "aws-access-key",
+		Usage:  "AWS access `key` for programmatic access",
+		EnvVar: "AWS_ACCESS_KEY_ID",
 	}
 	AwsSecretKeyFlag = cli.StringFlag{
-		Name:  "aws-secret-key",
-		Usage: "AWS secret `key` for programmatic access",
+		Name:   "aws-secret-key",
+		Usage:  "AWS secret `key` for programmatic access",
+		EnvVar: "AWS_SECRET_ACCESS_KEY",
+	}
+	AwsSessionTokenFlag = cli.StringFlag{
+		Name:   "aws-session-token",
+		Usage:  "An optional AWS session `token` for temporary credentials",
+		EnvVar: "AWS_SESSION_TOKEN",
 	}
 	AwsRegionFlag = cli.StringFlag{
 		Name:  "aws-region",
-		Usage: "An optional AWS region (default: random)",
+		Usage: "Optional comma separated AWS regions, Darknodes are spread across them (default: random)",
 	}
 	AwsInstanceFlag = cli.StringFlag{
 		Name:  "aws-instance",
@@ -74,9 +147,10 @@ var (
 		Usage: "An optional AWS EC2 instance type (default: t3.micro)",
 	}
 	AwsProfileFlag = cli.StringFlag{
-		Name:  "aws-profile",
-		Value: "default",
-		Usage: "Name of the profile containing the credentials",
+		Name:   "aws-profile",
+		Value:  "default",
+		Usage:  "Name of the profile containing the credentials, which can be an assumed role or SSO profile",
+		EnvVar: "AWS_PROFILE",
 	}
 )
 
@@ -87,12 +161,13 @@ var (
 		Usage: "Digital Ocean will be used to provision the Darknode",
 	}
 	DoTokenFlag = cli.StringFlag{
-		Name:  "do-token",
-		Usage: "Digital Ocean API token for programmatic access",
+		Name:   "do-token",
+		Usage:  "Digital Ocean API token for programmatic access",
+		EnvVar: "DIGITALOCEAN_TOKEN",
 	}
 	DoRegionFlag = cli.StringFlag{
 		Name:  "do-region",
-		Usage: "An optional Digital Ocean region (default: random)",
+		Usage: "Optional comma separated Digital Ocean regions, Darknodes are spread across them (default: random)",
 	}
 	DoSizeFlag = cli.StringFlag{
 		Name:  "do-droplet",
@@ -108,8 +183,13 @@ var (
 		Usage: "Google Cloud Platform will be used to provision the Darknode",
 	}
 	GcpCredFlag = cli.StringFlag{
-		Name:  "gcp-credentials",
-		Usage: "Path of the Service Account credential file (JSON) to be used",
+		Name:   "gcp-credentials",
+		Usage:  "Path of the Service Account credential file (JSON) to be used (default: Application Default Credentials)",
+		EnvVar: "GOOGLE_APPLICATION_CREDENTIALS",
+	}
+	GcpProjectFlag = cli.StringFlag{
+		Name:  "gcp-project",
+		Usage: "An optional Google Cloud project ID, required if it cannot be inferred from the credentials",
 	}
 	GcpMachineFlag = cli.StringFlag{
 		Name:  "gcp-machine",
@@ -118,6 +198,54 @@ var (
 	}
 	GcpZoneFlag = cli.StringFlag{
 		Name:  "gcp-zone",
-		Usage: "An optional Google Cloud Zone (default: random)",
+		Usage: "Optional comma separated Google Cloud zones, Darknodes are spread across them (default: random)",
+	}
+)
+
+// Hetzner Cloud flags
+var (
+	HetznerFlag = cli.BoolFlag{
+		Name:  provider.NameHetzner,
+		Usage: "Hetzner Cloud will be used to provision the Darknode",
+	}
+	HetznerTokenFlag = cli.StringFlag{
+		Name:   "hetzner-token",
+		Usage:  "Hetzner Cloud API token for programmatic access",
+		EnvVar: "HCLOUD_TOKEN",
+	}
+	HetznerLocationFlag = cli.StringFlag{
+		Name:  "hetzner-location",
+		Usage: "Optional comma separated Hetzner Cloud locations, Darknodes are spread across them (default: random)",
+	}
+	HetznerServerTypeFlag = cli.StringFlag{
+		Name:  "hetzner-server-type",
+		Value: "cx22",
+		Usage: "An optional Hetzner Cloud server type (default: cx22)",
+	}
+)
+
+// SSH flags for deploying to an existing server
+var (
+	SshHostFlag = cli.StringFlag{
+		Name:  "ssh-host",
+		Usage: "Hostname or IP address of an existing server the Darknode will be deployed to",
+	}
+	SshUserFlag = cli.StringFlag{
+		Name:  "ssh-user",
+		Value: "root",
+		Usage: "An optional user with passwordless sudo on the server (default: root)",
+	}
+	SshKeyFlag = cli.StringFlag{
+		Name:  "ssh-key",
+		Value: "~/.ssh/id_rsa",
+		Usage: "Path of the private key for logging into the server (default: ~/.ssh/id_rsa)",
+	}
+)
+
+// Docker flags
+var (
+	DockerFlag = cli.BoolFlag{
+		Name:  provider.NameDocker,
+		Usage: "A local Docker container will be used to run the Darknode, for development and testing",
 	}
 )
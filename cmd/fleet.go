@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/hashicorp/go-version"
+	"github.com/renproject/darknode-cli/cmd/provider"
+	"github.com/renproject/darknode-cli/darknode"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/urfave/cli"
+)
+
+// Kinds of changes needed to bring the darknodes to the state in the fleet file.
+const (
+	actionCreate   = "create"
+	actionResize   = "resize"
+	actionUpdate   = "update"
+	actionTag      = "tag"
+	actionFirewall = "firewall"
+	actionDestroy  = "destroy"
+	actionSkip     = "skip"
+)
+
+// fleetAction is a change to a single darknode.
+type fleetAction struct {
+	kind string
+	node util.FleetNode
+	from string
+	to   string
+}
+
+// String implements the `fmt.Stringer` interface.
+func (action fleetAction) String() string {
+	switch action.kind {
+	case actionCreate:
+		return fmt.Sprintf("+ %-8s %v (%v)", action.kind, action.node.Name, action.to)
+	case actionDestroy:
+		return fmt.Sprintf("- %-8s %v", action.kind, action.node.Name)
+	case actionSkip:
+		return fmt.Sprintf("! %-8s %v: %v", action.kind, action.node.Name, action.to)
+	default:
+		return fmt.Sprintf("~ %-8s %v: %v -> %v", action.kind, action.node.Name, action.from, action.to)
+	}
+}
+
+// planFleet prints the changes needed to bring the darknodes to the state in
+// the fleet file.
+func planFleet(ctx *cli.Context) error {
+	fleet, err := util.ReadFleet(ctx.String("file"))
+	if err != nil {
+		return err
+	}
+	actions, err := fleetActions(fleet)
+	if err != nil {
+		return err
+	}
+	printActions(actions)
+	return nil
+}
+
+// applyFleet performs the changes needed to bring the darknodes to the state in
+// the fleet file. New darknodes are deployed in parallel, destroying a darknode
+// still needs to be confirmed and it must have been deregistered and refunded.
+func applyFleet(ctx *cli.Context) error {
+	fleet, err := util.ReadFleet(ctx.String("file"))
+	if err != nil {
+		return err
+	}
+	actions, err := fleetActions(fleet)
+	if err != nil {
+		return err
+	}
+	printActions(actions)
+	if !hasChanges(actions) {
+		return nil
+	}
+
+	var creates []fleetAction
+	var errs []error
+	for _, action := range actions {
+		if action.kind == actionCreate {
+			creates = append(creates, action)
+		}
+	}
+	// Nothing is changed if any of the new darknodes cannot be deployed.
+	if len(creates) > 0 {
+		ps, deployments, err := prepareFleetNodes(ctx, creates)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, deployAll(ps, deployments, ctx.Int("concurrency")))
+	}
+
+	// Validate each release once before updating the darknodes.
+	validVersions := map[string]error{}
+	for _, action := range actions {
+		name := action.node.Name
+		var err error
+		switch action.kind {
+		case actionResize:
+			color.Green("[%v] resizing to %v ...", name, action.to)
+			err = resizeNode(ctx, name, action.to)
+		case actionUpdate:
+			if _, ok := validVersions[action.to]; !ok {
+				validVersions[action.to] = validateVersion(action.to)
+			}
+			if err = validVersions[action.to]; err == nil {
+				err = updateSingleNode(name, action.to, false)
+			}
+		case actionTag:
+			err = util.UpdateMetadata(name, func(meta *util.Metadata) {
+				meta.Tags = action.node.Tags
+			})
+		case actionFirewall:
+			var p provider.Provider
+			if p, err = provider.ParseNodeProvider(ctx, name); err == nil {
+				err = p.AllowSSH(name, action.node.SSHCIDRs)
+			}
+		case actionDestroy:
+			err = destroySingleNode(ctx, name, false)
+		default:
+			continue
+		}
+		if err != nil {
+			color.Red("[%v] cannot %v the darknode: %v", name, action.kind, err)
+		}
+		errs = append(errs, err)
+	}
+	return util.HandleErrs(errs)
+}
+
+// prepareFleetNodes validates the new darknodes in the fleet file and returns
+// their providers and deployments.
+func prepareFleetNodes(ctx *cli.Context, creates []fleetAction) ([]provider.Provider, []provider.Deployment, error) {
+	ps := make([]provider.Provider, len(creates))
+	deployments := make([]provider.Deployment, len(creates))
+	providers := map[string]provider.Provider{}
+	var latestVersion string
+
+	// Each provider is asked for the placement of each darknode, but with the
+	// number of all new darknodes on it, so the quota of the account is
+	// checked for all of them.
+	counts := map[string]int{}
+	for _, action := range creates {
+		counts[action.node.Provider]++
+	}
+	for i, action := range creates {
+		node := action.node
+		p, ok := providers[node.Provider]
+		if !ok {
+			var err error
+			p, err = provider.NewProvider(ctx, node.Provider)
+			if err != nil {
+				return nil, nil, err
+			}
+			providers[node.Provider] = p
+		}
+		d := provider.Deployment{Network: darknode.Network(node.Network), StaticIP: node.StaticIP, Spot: node.Spot, SSHCIDRs: node.SSHCIDRs}
+		if err := provider.ValidateDeployment(p, d); err != nil {
+			return nil, nil, fmt.Errorf("[%v] %v", node.Name, err)
+		}
+		var regions []string
+		if node.Region != "" {
+			regions = []string{node.Region}
+		}
+		regions, err := p.Placements(regions, node.Instance, counts[node.Provider])
+		if err != nil {
+			return nil, nil, fmt.Errorf("[%v] %v", node.Name, err)
+		}
+		image, err := provider.ParseImage(node.Image)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[%v] %v", node.Name, err)
+		}
+
+		version := node.Version
+		if version == "" {
+			if latestVersion == "" {
+				if latestVersion, err = util.LatestStableRelease(); err != nil {
+					return nil, nil, err
+				}
+			}
+			version = latestVersion
+		}
+
+		ps[i] = p
+		deployments[i] = provider.Deployment{
+			Name:     node.Name,
+			Tags:     strings.Join(node.Tags, ","),
+			Network:  darknode.Network(node.Network),
+			Region:   regions[0],
+			Instance: node.Instance,
+			Version:  version,
+			Image:    image,
+			IPv6:     node.IPv6,
+			StaticIP: node.StaticIP,
+			Spot:     node.Spot,
+			SSHCIDRs: node.SSHCIDRs,
+		}
+	}
+	return ps, deployments, nil
+}
+
+// fleetActions compares the fleet with the darknodes in the darknodes directory
+// and returns the changes needed. Changes which can only be made by redeploying
+// the darknode are returned as an error. Darknodes without readable metadata,
+// e.g. the ones which need to be migrated, are skipped and never destroyed.
+func fleetActions(fleet util.Fleet) ([]fleetAction, error) {
+	files, err := ioutil.ReadDir(filepath.Join(util.Directory, "darknodes"))
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]util.Metadata{}
+	unmanaged := map[string]error{}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		names = append(names, f.Name())
+		meta, err := util.ReadMetadata(f.Name())
+		if err != nil {
+			unmanaged[f.Name()] = err
+			continue
+		}
+		existing[f.Name()] = meta
+	}
+
+	var skips, creates, changes, destroys []fleetAction
+	wanted := map[string]bool{}
+	for _, node := range fleet.Nodes {
+		wanted[node.Name] = true
+		if len(node.SSHCIDRs) > 0 {
+			cidrs, err := provider.ParseCIDRs(node.SSHCIDRs)
+			if err != nil {
+				return nil, fmt.Errorf("darknode [%v] has %v", node.Name, err)
+			}
+			node.SSHCIDRs = cidrs
+		}
+		if err, ok := unmanaged[node.Name]; ok {
+			skips = append(skips, fleetAction{kind: actionSkip, node: node, to: err.Error()})
+			continue
+		}
+		meta, ok := existing[node.Name]
+		if !ok {
+			if node.Provider == provider.NameSsh {
+				return nil, fmt.Errorf("darknode [%v] cannot be deployed to an existing server from a fleet file", node.Name)
+			}
+			to := []string{node.Provider}
+			for _, detail := range []string{node.Region, node.Instance, node.Network, node.Version} {
+				if detail != "" {
+					to = append(to, detail)
+				}
+			}
+			for _, option := range []struct {
+				name    string
+				enabled bool
+			}{{"ipv6", node.IPv6}, {"static ip", node.StaticIP}, {"spot", node.Spot}} {
+				if option.enabled {
+					to = append(to, option.name)
+				}
+			}
+			creates = append(creates, fleetAction{kind: actionCreate, node: node, to: strings.Join(to, ", ")})
+			continue
+		}
+
+		if node.Provider != meta.Provider {
+			return nil, fmt.Errorf("darknode [%v] is deployed on %v, destroy it first to move it to %v", node.Name, meta.Provider, node.Provider)
+		}
+		if node.Network != string(meta.Network) {
+			return nil, fmt.Errorf("darknode [%v] is on %v, destroy it first to move it to %v", node.Name, meta.Network, node.Network)
+		}
+		if node.Region != "" && node.Region != meta.Region {
+			return nil, fmt.Errorf("darknode [%v] is deployed in %v, destroy it first to move it to %v", node.Name, meta.Region, node.Region)
+		}
+		for _, option := range []struct {
+			name           string
+			current, wants bool
+		}{{"ipv6", meta.IPv6, node.IPv6}, {"staticIP", meta.StaticIP, node.StaticIP}, {"spot", meta.Spot, node.Spot}} {
+			if option.current != option.wants {
+				return nil, fmt.Errorf("darknode [%v] has %v = %v, destroy it first to deploy it with %v = %v", node.Name, option.name, option.current, option.name, option.wants)
+			}
+		}
+		if node.Image != "" {
+			image, err := provider.ParseImage(node.Image)
+			if err != nil {
+				return nil, fmt.Errorf("darknode [%v] has %v", node.Name, err)
+			}
+			if image.Name != meta.Image {
+				current := meta.Image
+				if current == "" {
+					current = "an unknown image"
+				}
+				return nil, fmt.Errorf("darknode [%v] runs %v, destroy it first to deploy it with %v", node.Name, current, image.Name)
+			}
+		}
+		if node.Instance != "" && node.Instance != meta.Instance {
+			changes = append(changes, fleetAction{kind: actionResize, node: node, from: meta.Instance, to: node.Instance})
+		}
+		if node.Version != "" {
+			upgrade, err := needsUpgrade(node.Name, meta.DarknodeVersion, node.Version)
+			if err != nil {
+				return nil, err
+			}
+			if upgrade {
+				changes = append(changes, fleetAction{kind: actionUpdate, node: node, from: meta.DarknodeVersion, to: node.Version})
+			}
+		}
+		if strings.Join(node.Tags, ",") != strings.Join(meta.Tags, ",") {
+			changes = append(changes, fleetAction{kind: actionTag, node: node, from: strings.Join(meta.Tags, ","), to: strings.Join(node.Tags, ",")})
+		}
+		action, err := firewallAction(node)
+		if err != nil {
+			return nil, err
+		}
+		if action != nil {
+			changes = append(changes, *action)
+		}
+	}
+	for _, name := range names {
+		if wanted[name] {
+			continue
+		}
+		if err, ok := unmanaged[name]; ok {
+			skips = append(skips, fleetAction{kind: actionSkip, node: util.FleetNode{Name: name}, to: err.Error()})
+			continue
+		}
+		destroys = append(destroys, fleetAction{kind: actionDestroy, node: util.FleetNode{Name: name}})
+	}
+	return append(append(append(skips, creates...), changes...), destroys...), nil
+}
+
+// needsUpgrade returns whether the darknode needs to be updated to the version
+// in the fleet file. Downgrading is an error, it can only be done with
+// `darknode update --force`.
+func needsUpgrade(name, current, wanted string) (bool, error) {
+	wantedVersion, err := version.NewVersion(wanted)
+	if err != nil {
+		return false, fmt.Errorf("darknode [%v] has invalid version %v, err = %v", name, wanted, err)
+	}
+	currentVersion, err := version.NewVersion(current)
+	if err != nil {
+		// The version running on the darknode is unknown.
+		return true, nil
+	}
+	if wantedVersion.LessThan(currentVersion) {
+		return false, fmt.Errorf("darknode [%v] is running version %v, downgrading it to %v is only possible with `darknode update --force`", name, current, wanted)
+	}
+	return wantedVersion.GreaterThan(currentVersion), nil
+}
+
+// firewallAction returns the change needed to restrict SSH of the existing
+// darknode to the CIDR ranges in the fleet file, or nil if there's none.
+func firewallAction(node util.FleetNode) (*fleetAction, error) {
+	wanted := node.SSHCIDRs
+	if len(wanted) == 0 {
+		wanted = provider.DefaultSSHAllowList
+	}
+	current, err := provider.SSHAllowList(node.Name)
+	if err == provider.ErrFirewallNotManaged {
+		if len(node.SSHCIDRs) > 0 {
+			return nil, fmt.Errorf("darknode [%v] was deployed without an SSH allow-list, run `darknode migrate` first to restrict SSH", node.Name)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sortedJoin(current) == sortedJoin(wanted) {
+		return nil, nil
+	}
+	node.SSHCIDRs = wanted
+	return &fleetAction{kind: actionFirewall, node: node, from: strings.Join(current, ","), to: strings.Join(wanted, ",")}, nil
+}
+
+// sortedJoin joins a sorted copy of the values with commas.
+func sortedJoin(values []string) string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// hasChanges returns whether any of the actions changes a darknode.
+func hasChanges(actions []fleetAction) bool {
+	for _, action := range actions {
+		if action.kind != actionSkip {
+			return true
+		}
+	}
+	return false
+}
+
+// printActions prints the changes and a summary of them.
+func printActions(actions []fleetAction) {
+	counts := map[string]int{}
+	for _, action := range actions {
+		counts[action.kind]++
+		switch action.kind {
+		case actionCreate:
+			color.Green(action.String())
+		case actionDestroy, actionSkip:
+			color.Red(action.String())
+		default:
+			color.Yellow(action.String())
+		}
+	}
+	if counts[actionSkip] > 0 {
+		fmt.Printf("\n%v darknodes are skipped as their metadata cannot be read.\n", counts[actionSkip])
+	}
+	if !hasChanges(actions) {
+		color.Green("All darknodes match the fleet file, no changes are needed.")
+		return
+	}
+	fmt.Printf("\nPlan: %v to create, %v to resize, %v to update, %v to retag, %v to update the firewall, %v to destroy.\n",
+		counts[actionCreate], counts[actionResize], counts[actionUpdate], counts[actionTag], counts[actionFirewall], counts[actionDestroy])
+}
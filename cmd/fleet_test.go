@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/renproject/darknode-cli/darknode"
+	"github.com/renproject/darknode-cli/util"
+)
+
+func TestFleetActions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "darknode-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDirectory := util.Directory
+	util.Directory = dir
+	defer func() {
+		util.Directory = oldDirectory
+		os.RemoveAll(dir)
+	}()
+
+	// Darknode [a] has metadata, [legacy] and [old] were deployed by an older
+	// version of the CLI.
+	for _, name := range []string{"a", "b", "legacy", "old"} {
+		if err := os.MkdirAll(util.NodePath(name), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, meta := range []util.Metadata{
+		{Name: "a", Network: darknode.Testnet, Provider: "aws", Region: "eu-west-1", Instance: "t3.micro", Tags: []string{"eu"}, DarknodeVersion: "0.4.0"},
+		{Name: "b", Network: darknode.Testnet, Provider: "aws", Region: "eu-west-1", Instance: "t3.micro"},
+	} {
+		if err := util.WriteMetadata(meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fleet := util.Fleet{Nodes: []util.FleetNode{
+		{Name: "a", Provider: "aws", Network: "testnet", Instance: "t3.small", Tags: []string{"eu", "canary"}, Version: "0.4.0"},
+		{Name: "legacy", Provider: "aws", Network: "testnet"},
+		{Name: "c", Provider: "do", Network: "testnet", Region: "nyc1", StaticIP: true, SSHCIDRs: []string{"10.0.0.1"}},
+	}}
+	actions, err := fleetActions(fleet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, action := range actions {
+		got = append(got, action.kind+" "+action.node.Name)
+	}
+	want := []string{"skip legacy", "skip old", "create c", "resize a", "tag a", "destroy b"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if actions[0].to == "" || !strings.Contains(actions[0].to, "migrate") {
+		t.Fatalf("skipped darknode does not tell to migrate: %v", actions[0])
+	}
+	if create := actions[2]; create.to != "do, nyc1, testnet, static ip" || create.node.SSHCIDRs[0] != "10.0.0.1/32" {
+		t.Fatalf("unexpected create %v, %v", create, create.node.SSHCIDRs)
+	}
+
+	// Options which need redeploying the darknode are rejected.
+	fleet.Nodes[0].Spot = true
+	if _, err := fleetActions(fleet); err == nil || !strings.Contains(err.Error(), "spot") {
+		t.Fatalf("expected spot to be rejected, got %v", err)
+	}
+	fleet.Nodes[0].Spot = false
+	fleet.Nodes[0].Image = "ubuntu-22.04"
+	if _, err := fleetActions(fleet); err == nil || !strings.Contains(err.Error(), "destroy it first") {
+		t.Fatalf("expected the image change to be rejected, got %v", err)
+	}
+	fleet.Nodes[0].Image = ""
+
+	// Darknodes are only upgraded.
+	fleet.Nodes[0].Version = "0.3.9"
+	if _, err := fleetActions(fleet); err == nil || !strings.Contains(err.Error(), "downgrading") {
+		t.Fatalf("expected the downgrade to be rejected, got %v", err)
+	}
+	fleet.Nodes[0].Version = "0.4.1"
+	actions, err = fleetActions(fleet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update := actions[4]; update.kind != actionUpdate || update.from != "0.4.0" || update.to != "0.4.1" {
+		t.Fatalf("unexpected action %v", update)
+	}
+	fleet.Nodes[0].Version = "0.4.0"
+
+	fleet.Nodes[0].SSHCIDRs = []string{"10.0.0.0/8"}
+	if _, err := fleetActions(fleet); err == nil || !strings.Contains(err.Error(), "SSH allow-list") {
+		t.Fatalf("expected the SSH allow-list to be rejected, got %v", err)
+	}
+}
@@ -2,12 +2,10 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"strings"
 	"sync/atomic"
 
 	"github.com/fatih/color"
-	"github.com/renproject/darknode-cli/cmd/provider"
 	"github.com/renproject/darknode-cli/util"
 	"github.com/renproject/phi"
 	"github.com/urfave/cli"
@@ -31,16 +29,7 @@ func listAllNodes(ctx *cli.Context) error {
 			if err != nil {
 				return nil, err
 			}
-			ip, err := util.IP(name)
-			if err != nil {
-				return nil, err
-			}
-			provider, err := provider.GetProvider(name)
-			if err != nil {
-				return nil, err
-			}
-			tagFile := filepath.Join(util.NodePath(nodesNames[i]), "tags.out")
-			tags, err := ioutil.ReadFile(tagFile)
+			meta, err := util.ReadMetadata(name)
 			if err != nil {
 				return nil, err
 			}
@@ -48,8 +37,7 @@ func listAllNodes(ctx *cli.Context) error {
 			if err != nil {
 				return nil, err
 			}
-			version := util.Version(name)
-			return []string{name, id.String(), ip, provider, string(tags), ethAddr.Hex(), version}, nil
+			return []string{name, id.String(), meta.IP, meta.Provider, strings.Join(meta.Tags, ","), ethAddr.Hex(), meta.DarknodeVersion}, nil
 		}()
 		if err != nil {
 			color.Red("[%v] cannot get detail of the darknode, err = %v", name, err)
@@ -11,7 +11,6 @@ import (
 	"github.com/fatih/color"
 	"github.com/google/go-github/github"
 	"github.com/hashicorp/go-version"
-	"github.com/renproject/darknode-cli/cmd/provider"
 	"github.com/renproject/darknode-cli/util"
 	"github.com/urfave/cli"
 )
@@ -40,27 +39,61 @@ func main() {
 			Usage: "Deploy a new Darknode",
 			Flags: []cli.Flag{
 				// General
-				NameFlag, TagsFlag, NetworkFlag, ConfigFlag,
+				NameFlag, TagsFlag, NetworkFlag, ConfigFlag, ImageFlag, IPv6Flag, StaticIPFlag, SpotFlag,
+				CountFlag, NamePrefixFlag, ConcurrencyFlag,
 				// AWS
-				AwsFlag, AwsAccessKeyFlag, AwsSecretKeyFlag, AwsInstanceFlag, AwsRegionFlag, AwsProfileFlag,
+				AwsFlag, AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsInstanceFlag, AwsRegionFlag, AwsProfileFlag,
 				// Digital Ocean
 				DoFlag, DoRegionFlag, DoSizeFlag, DoTokenFlag,
 				// Google Cloud Platform
-				GcpFlag, GcpZoneFlag, GcpCredFlag, GcpMachineFlag,
+				GcpFlag, GcpZoneFlag, GcpCredFlag, GcpProjectFlag, GcpMachineFlag,
+				// Hetzner Cloud
+				HetznerFlag, HetznerTokenFlag, HetznerLocationFlag, HetznerServerTypeFlag,
+				// Existing server
+				SshHostFlag, SshUserFlag, SshKeyFlag,
+				// Local docker container
+				DockerFlag,
 			},
+			Before: checkTerraform,
 			Action: func(c *cli.Context) error {
-				p, err := provider.ParseProvider(c)
-				if err != nil {
-					return err
-				}
-				return p.Deploy(c)
+				return up(c)
+			},
+		},
+		{
+			Name:  "plan",
+			Usage: "Show the changes needed to make the Darknodes match the fleet file",
+			Flags: []cli.Flag{FleetFlag},
+			Action: func(c *cli.Context) error {
+				return planFleet(c)
+			},
+		},
+		{
+			Name:  "apply",
+			Usage: "Create, resize, update and destroy Darknodes to match the fleet file",
+			Flags: []cli.Flag{
+				FleetFlag, ConcurrencyFlag,
+				AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsProfileFlag,
+				DoTokenFlag,
+				GcpCredFlag,
+				HetznerTokenFlag,
+			},
+			Before: checkTerraform,
+			Action: func(c *cli.Context) error {
+				return applyFleet(c)
 			},
 		},
 		{
 			Name:    "destroy",
 			Usage:   "Destroy one of your Darknode",
 			Aliases: []string{"down"},
-			Flags:   []cli.Flag{TagsFlag, ForceFlag},
+			Flags: []cli.Flag{
+				TagsFlag, ForceFlag,
+				AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsProfileFlag,
+				DoTokenFlag,
+				GcpCredFlag,
+				HetznerTokenFlag,
+			},
+			Before: checkTerraform,
 			Action: func(c *cli.Context) error {
 				return destroyNode(c)
 			},
@@ -87,7 +120,7 @@ func main() {
 					return err
 				}
 				keyPath := filepath.Join(util.NodePath(name), "ssh_keypair")
-				return util.Run("ssh", "-i", keyPath, "darknode@"+ip, "-oStrictHostKeyChecking=no")
+				return util.Run("ssh", "-i", keyPath, "-p", util.SSHPort(name), "darknode@"+ip, "-oStrictHostKeyChecking=no")
 			},
 		},
 		{
@@ -122,6 +155,20 @@ func main() {
 				return listAllNodes(c)
 			},
 		},
+		{
+			Name:  "cost",
+			Usage: "Estimate the monthly cost of your Darknodes",
+			Flags: []cli.Flag{
+				TagsFlag, JsonFlag,
+				AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsProfileFlag,
+				DoTokenFlag,
+				GcpCredFlag, GcpProjectFlag,
+				HetznerTokenFlag,
+			},
+			Action: func(c *cli.Context) error {
+				return estimateCost(c)
+			},
+		},
 		{
 			Name:  "withdraw",
 			Usage: "Withdraw all the ETH and REN the Darknode address holds",
@@ -133,11 +180,143 @@ func main() {
 		{
 			Name:  "resize",
 			Usage: "Resize the instance type of a specific darknode",
-			Flags: []cli.Flag{},
+			Flags: []cli.Flag{
+				AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsProfileFlag,
+				DoTokenFlag,
+				GcpCredFlag,
+				HetznerTokenFlag,
+			},
+			Before: checkTerraform,
 			Action: func(c *cli.Context) error {
 				return resize(c)
 			},
 		},
+		{
+			Name:  "firewall",
+			Usage: "Manage the firewall of Darknodes",
+			Subcommands: []cli.Command{
+				{
+					Name:  "allow-ssh",
+					Usage: "Only allow SSH to Darknodes from the given CIDR ranges",
+					Flags: []cli.Flag{
+						TagsFlag, CidrFlag,
+						AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsProfileFlag,
+						DoTokenFlag,
+						GcpCredFlag,
+						HetznerTokenFlag,
+					},
+					Before: checkTerraform,
+					Action: func(c *cli.Context) error {
+						return allowSSH(c)
+					},
+				},
+				{
+					Name:  "show",
+					Usage: "Show the firewall rules of Darknodes",
+					Flags: []cli.Flag{TagsFlag},
+					Action: func(c *cli.Context) error {
+						return showFirewall(c)
+					},
+				},
+			},
+		},
+		{
+			Name:  "tag",
+			Usage: "Manage the tags of Darknodes",
+			Subcommands: []cli.Command{
+				{
+					Name:      "add",
+					Usage:     "Add tags to a Darknode",
+					ArgsUsage: "NAME TAGS",
+					Action: func(c *cli.Context) error {
+						return addTags(c)
+					},
+				},
+				{
+					Name:      "remove",
+					Usage:     "Remove tags from a Darknode",
+					ArgsUsage: "NAME TAGS",
+					Action: func(c *cli.Context) error {
+						return removeTags(c)
+					},
+				},
+				{
+					Name:      "set",
+					Usage:     "Replace the tags of a Darknode",
+					ArgsUsage: "NAME TAGS",
+					Action: func(c *cli.Context) error {
+						return setTags(c)
+					},
+				},
+				{
+					Name:      "list",
+					Usage:     "List the tags of a Darknode, or of all Darknodes",
+					ArgsUsage: "[NAME]",
+					Flags:     []cli.Flag{TagsFlag},
+					Action: func(c *cli.Context) error {
+						return listTags(c)
+					},
+				},
+			},
+		},
+		{
+			Name:  "watchdog",
+			Usage: "Redeploy Darknodes on spot instances when their instance is reclaimed",
+			Flags: []cli.Flag{
+				TagsFlag, IntervalFlag, OnceFlag, FailuresFlag,
+				AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsProfileFlag,
+				GcpCredFlag,
+			},
+			Before: checkTerraform,
+			Action: func(c *cli.Context) error {
+				return watchdog(c)
+			},
+		},
+		{
+			Name:  "regions",
+			Usage: "List all regions of a cloud provider",
+			Flags: []cli.Flag{
+				JsonFlag, RefreshFlag,
+				AwsFlag, AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsProfileFlag,
+				DoFlag, DoTokenFlag,
+				GcpFlag, GcpCredFlag, GcpProjectFlag,
+				HetznerFlag, HetznerTokenFlag,
+			},
+			Action: func(c *cli.Context) error {
+				return listRegions(c)
+			},
+		},
+		{
+			Name:  "sizes",
+			Usage: "List all instance types of a cloud provider in a region, with their hardware and price",
+			Flags: []cli.Flag{
+				RegionFlag, JsonFlag, RefreshFlag,
+				AwsFlag, AwsAccessKeyFlag, AwsSecretKeyFlag, AwsSessionTokenFlag, AwsProfileFlag,
+				DoFlag, DoTokenFlag,
+				GcpFlag, GcpCredFlag, GcpProjectFlag,
+				HetznerFlag, HetznerTokenFlag,
+			},
+			Action: func(c *cli.Context) error {
+				return listSizes(c)
+			},
+		},
+		{
+			Name:   "migrate",
+			Usage:  "Upgrade the directories of Darknodes deployed by older versions of the CLI",
+			Flags:  []cli.Flag{},
+			Before: checkTerraform,
+			Action: func(c *cli.Context) error {
+				return migrate(c)
+			},
+		},
+		{
+			Name:  "doctor",
+			Usage: "Check the tools needed by the CLI and install the pinned version of terraform",
+			Flags: []cli.Flag{},
+			Action: func(c *cli.Context) error {
+				return doctor(c)
+			},
+		},
 		{
 			Name:  "exec",
 			Usage: "Execute script on Darknodes",
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/cmd/provider"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/renproject/phi"
+	"github.com/urfave/cli"
+)
+
+// migration upgrades the directory of a darknode deployed by an older version of
+// the CLI. It returns whether the node directory has been changed.
+type migration struct {
+	description string
+	run         func(name string) (bool, error)
+}
+
+// migrations are run in order on each darknode by the `migrate` command. They
+// need to be safe to run multiple times.
+var migrations = []migration{
+	{
+		description: "removed cloud credentials from the node directory",
+		run:         provider.ScrubCredentials,
+	},
+	{
+		description: "upgraded the terraform config and state to terraform " + util.TerraformVersion,
+		run:         provider.UpgradeTerraform,
+	},
+	{
+		description: "moved the terraform state to the remote backend",
+		run:         provider.MigrateState,
+	},
+	{
+		description: "created the node.json metadata file",
+		run:         provider.CreateMetadata,
+	},
+	{
+		description: "added the SSH allow-list to the firewall, apply it with `darknode firewall allow-ssh`",
+		run:         provider.MigrateFirewall,
+	},
+}
+
+// migrate runs all the migrations on a single darknode or all darknodes.
+func migrate(ctx *cli.Context) error {
+	name := ctx.Args().First()
+	var nodes []string
+	if name != "" {
+		// The metadata may not exist yet, so only check the node directory.
+		if _, err := os.Stat(util.NodePath(name)); err != nil {
+			return fmt.Errorf("darknode [%v] not found", name)
+		}
+		nodes = []string{name}
+	} else {
+		files, err := ioutil.ReadDir(filepath.Join(util.Directory, "darknodes"))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				nodes = append(nodes, f.Name())
+			}
+		}
+	}
+
+	errs := make([]error, len(nodes))
+	phi.ParForAll(nodes, func(i int) {
+		for _, m := range migrations {
+			changed, err := m.run(nodes[i])
+			if err != nil {
+				color.Red("[%v] migration failed: %v", nodes[i], err)
+				errs[i] = err
+				return
+			}
+			if changed {
+				color.Green("[%v] %v", nodes[i], m.description)
+			}
+		}
+	})
+	return util.HandleErrs(errs)
+}
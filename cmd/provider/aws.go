@@ -1,117 +1,536 @@
 package provider
 
 import (
+	"encoding/json"
 	"fmt"
-	"math/rand"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/renproject/darknode-cli/darknode"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/fatih/color"
 	"github.com/renproject/darknode-cli/util"
 	"github.com/urfave/cli"
 )
 
-// All available regions on AWS.
-var AllAwsRegions = []string{
-	"ap-northeast-1",
-	"ap-northeast-2",
-	"ap-south-1",
-	"ap-southeast-1",
-	"ap-southeast-2",
-	"ca-central-1",
-	"eu-central-1",
-	"eu-north-1",
-	"eu-west-1",
-	"eu-west-2",
-	"eu-west-3",
-	"sa-east-1",
-	"us-east-1",
-	"us-east-2",
-	"us-west-1",
-	"us-west-2",
+// Regex for finding the instance type and region in the terraform config.
+var (
+	RegexAws = `instance_type\s+=\s*"(?P<instance>.+)"`
+
+	// The region is matched in the provider block, the backend block of the
+	// remote state has a region as well.
+	RegexAwsRegion = `provider\s+"aws"\s*\{[^}]*?\bregion\s*=\s*"(?P<region>[^"]+)"`
+)
+
+// awsResourceActions are the IAM actions needed by terraform to create, update
+// and destroy each type of resource in the AWS template.
+var awsResourceActions = map[string][]string{
+	"aws_ami": {"ec2:DescribeImages"},
+	"aws_vpc": {
+		"ec2:CreateVpc", "ec2:DeleteVpc", "ec2:DescribeVpcs", "ec2:DescribeVpcAttribute",
+		"ec2:ModifyVpcAttribute", "ec2:AssociateVpcCidrBlock", "ec2:DisassociateVpcCidrBlock",
+		"ec2:DescribeSecurityGroups", "ec2:DescribeNetworkAcls", "ec2:DescribeRouteTables",
+		"ec2:CreateTags", "ec2:DeleteTags",
+	},
+	"aws_internet_gateway": {
+		"ec2:CreateInternetGateway", "ec2:DeleteInternetGateway", "ec2:DescribeInternetGateways",
+		"ec2:AttachInternetGateway", "ec2:DetachInternetGateway", "ec2:CreateTags",
+	},
+	"aws_subnet": {
+		"ec2:CreateSubnet", "ec2:DeleteSubnet", "ec2:DescribeSubnets", "ec2:ModifySubnetAttribute",
+		"ec2:AssociateSubnetCidrBlock", "ec2:DisassociateSubnetCidrBlock", "ec2:CreateTags",
+	},
+	"aws_route_table": {
+		"ec2:CreateRouteTable", "ec2:DeleteRouteTable", "ec2:DescribeRouteTables",
+		"ec2:CreateRoute", "ec2:ReplaceRoute", "ec2:DeleteRoute", "ec2:CreateTags",
+	},
+	"aws_route_table_association": {
+		"ec2:AssociateRouteTable", "ec2:DisassociateRouteTable", "ec2:DescribeRouteTables",
+	},
+	"aws_security_group": {
+		"ec2:CreateSecurityGroup", "ec2:DeleteSecurityGroup", "ec2:DescribeSecurityGroups",
+		"ec2:DescribeSecurityGroupRules", "ec2:DescribeNetworkInterfaces",
+		"ec2:AuthorizeSecurityGroupIngress", "ec2:RevokeSecurityGroupIngress",
+		"ec2:AuthorizeSecurityGroupEgress", "ec2:RevokeSecurityGroupEgress",
+		"ec2:CreateTags",
+	},
+	"aws_key_pair": {"ec2:ImportKeyPair", "ec2:DeleteKeyPair", "ec2:DescribeKeyPairs"},
+	"aws_instance": {
+		"ec2:RunInstances", "ec2:TerminateInstances", "ec2:StartInstances", "ec2:StopInstances",
+		"ec2:DescribeInstances", "ec2:DescribeInstanceAttribute", "ec2:DescribeInstanceTypes",
+		"ec2:DescribeInstanceCreditSpecifications", "ec2:ModifyInstanceAttribute",
+		"ec2:MonitorInstances", "ec2:UnmonitorInstances", "ec2:DescribeVolumes",
+		"ec2:DescribeNetworkInterfaces", "ec2:DescribeTags", "ec2:CreateTags",
+	},
+	"aws_eip": {
+		"ec2:AllocateAddress", "ec2:ReleaseAddress", "ec2:AssociateAddress",
+		"ec2:DisassociateAddress", "ec2:DescribeAddresses", "ec2:DescribeAddressesAttribute",
+		"ec2:CreateTags",
+	},
+	"null_resource": nil,
+}
+
+// AwsActions returns the IAM actions needed by terraform to manage all the
+// resources in the AWS template, including the ones which are only created
+// with some of the options, e.g. the elastic IP.
+func AwsActions() []string {
+	return permissionsOf(awsTemplate+awsNetworkTemplate+ufwResource("", "", "", ""), awsResourceActions)
+}
+
+// mfaTokenProvider reads the MFA code when assuming a role which needs one.
+var mfaTokenProvider = stscreds.StdinTokenProvider
+
+// RunUnattended makes the providers return an error instead of prompting the
+// user, e.g. for the MFA code of a role. It should be called before creating
+// any provider.
+func RunUnattended() {
+	mfaTokenProvider = func() (string, error) {
+		return "", ErrMFAUnattended
+	}
 }
 
 type providerAws struct {
-	accessKey string
-	secretKey string
+	credentials *credentials.Credentials
+
+	// profile is passed to terraform to resolve the credentials itself, so
+	// temporary credentials of roles and SSO are refreshed during long runs. It
+	// is empty if the credentials are given by flags or environment variables.
+	profile string
+
+	// mfa tells whether the credentials are of a role which needs an MFA code.
+	mfa bool
 }
 
+// NewAws resolves the AWS credentials and returns a new AWS provider. Credentials
+// given by flags or environment variables are used first. Otherwise the
+// credentials are resolved from the profile in the shared config and
+// credentials files, which supports assumed roles (with MFA), SSO and
+// credential processes. Terraform is given the profile to resolve them the same
+// way, except for roles with MFA as terraform cannot prompt for the code.
 func NewAws(ctx *cli.Context) (Provider, error) {
 	accessKey := ctx.String("aws-access-key")
 	secretKey := ctx.String("aws-secret-key")
+	sessionToken := ctx.String("aws-session-token")
+	profile := ctx.String("aws-profile")
 
-	// Try reading the credential files if user does not provide credentials directly
-	if accessKey == "" || secretKey == "" {
-		cred := credentials.NewSharedCredentials("", ctx.String("aws-profile"))
-		credValue, err := cred.Get()
+	var creds *credentials.Credentials
+	mfa := false
+	if accessKey != "" && secretKey != "" {
+		creds = credentials.NewStaticCredentials(accessKey, secretKey, sessionToken)
+	} else {
+		sess, err := session.NewSessionWithOptions(session.Options{
+			Profile:           profile,
+			SharedConfigState: session.SharedConfigEnable,
+			AssumeRoleTokenProvider: func() (string, error) {
+				mfa = true
+				return mfaTokenProvider()
+			},
+		})
 		if err != nil {
 			return nil, err
 		}
-		accessKey, secretKey = credValue.AccessKeyID, credValue.SecretAccessKey
-		if accessKey == "" || secretKey == "" {
-			return nil, err
-		}
+		creds = sess.Config.Credentials
+	}
+
+	credValue, err := creds.Get()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the credentials of aws profile [%v], if you are using SSO, please login with `aws sso login --profile %v` first, err = %v", profile, profile, err)
+	}
+	if credValue.AccessKeyID == "" || credValue.SecretAccessKey == "" {
+		return nil, fmt.Errorf("cannot find the credentials of aws profile [%v]", profile)
+	}
+
+	p := providerAws{
+		credentials: creds,
+		mfa:         mfa,
 	}
+	if !mfa && fromProfile(credValue.ProviderName) {
+		p.profile = profile
+	}
+	return p, nil
+}
 
-	return providerAws{
-		accessKey: accessKey,
-		secretKey: secretKey,
-	}, nil
+// fromProfile tells whether the credentials with the given provider name are
+// resolved from a profile in the shared config or credentials files.
+func fromProfile(providerName string) bool {
+	switch providerName {
+	case credentials.SharedCredsProviderName, stscreds.ProviderName, ssocreds.ProviderName, processcreds.ProviderName:
+		return true
+	}
+	return strings.HasPrefix(providerName, "SharedConfigCredentials")
 }
 
 func (p providerAws) Name() string {
 	return NameAws
 }
 
-func (p providerAws) Deploy(ctx *cli.Context) error {
-	name := ctx.String("name")
-	tags := ctx.String("tags")
-	config := ctx.String("config")
+// env returns the environment variables for passing the credentials to terraform.
+func (p providerAws) env() []string {
+	if p.profile != "" {
+		return []string{"AWS_PROFILE=" + p.profile}
+	}
 
-	latestVersion, err := util.LatestStableRelease()
+	// The credentials have been resolved by NewAws, Get only fails when
+	// refreshing the expired credentials of a role fails, which terraform
+	// reports as missing credentials.
+	value, err := p.credentials.Get()
 	if err != nil {
-		return err
+		return nil
 	}
-	region, instance, err := p.validateRegionAndInstance(ctx)
-	if err != nil {
-		return err
+	env := []string{
+		"AWS_ACCESS_KEY_ID=" + value.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + value.SecretAccessKey,
+	}
+	if value.SessionToken != "" {
+		env = append(env, "AWS_SESSION_TOKEN="+value.SessionToken)
 	}
+	return env
+}
 
-	// Initialization
-	network, err := darknode.NewNetwork(ctx.String("network"))
+func (p providerAws) Placements(regions []string, instance string, count int) ([]string, error) {
+	available, err := p.Regions()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := initNode(name, tags, network, config); err != nil {
-		return err
+	regions, err = selectRegions(regions, available, count)
+	if err != nil {
+		return nil, err
 	}
+	for _, region := range regions {
+		instances, err := p.offerings(region)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateInstanceType(instance, region, instances); err != nil {
+			return nil, err
+		}
+		if err := p.checkPermissions(region); err != nil {
+			return nil, err
+		}
+	}
+	return regions, nil
+}
 
-	// Generate terraform config and start deploying
-	if err := p.tfConfig(name, region, instance, latestVersion); err != nil {
+func (p providerAws) Deploy(d Deployment) error {
+	if d.IPv6 {
+		if err := p.ensureNetwork(d.Region, d.Quiet); err != nil {
+			return err
+		}
+	}
+	if err := p.tfConfig(d.Name, d.Region, d.Instance, d.Version, d.Image, d.IPv6, d.StaticIP, d.Spot); err != nil {
 		return err
 	}
-	if err := runTerraform(name); err != nil {
+	if err := runTerraform(d.Name, p.env(), d.Quiet); err != nil {
 		return err
 	}
+	if err := deployed(d); err != nil {
+		return err
+	}
+	return waitForReady(d.Name)
+}
+
+func (p providerAws) Destroy(name string) error {
+	return destroyTerraform(name, p.env())
+}
+
+func (p providerAws) Resize(name, instance string) error {
+	replacement := fmt.Sprintf(`instance_type   = "%v"`, instance)
+	return resizeTerraform(name, p.env(), RegexAws, replacement)
+}
+
+func (p providerAws) Status(name string) (Status, error) {
+	return statusTerraform(name)
+}
+
+func (p providerAws) AllowSSH(name string, cidrs []string) error {
+	return allowSSHTerraform(name, p.env(), cidrs)
+}
+
+func (p providerAws) Recover(name string) (bool, error) {
+	return recoverTerraform(name, p.env(), "aws_instance")
+}
+
+func (p providerAws) Instance(name string) (string, string, error) {
+	return instanceTerraform(name, RegexAwsRegion, RegexAws)
+}
+
+// Regions returns all the regions which are enabled for the account.
+func (p providerAws) Regions() ([]string, error) {
+	sess, err := p.session("us-east-1")
+	if err != nil {
+		return nil, err
+	}
+	output, err := ec2.New(sess).DescribeRegions(&ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, err
+	}
+	regions := make([]string, 0, len(output.Regions))
+	for _, region := range output.Regions {
+		regions = append(regions, aws.StringValue(region.RegionName))
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
 
-	return outputURL(name)
+// InstanceTypes returns all the instance types offered in the given region,
+// with the on-demand price of running Linux on them.
+func (p providerAws) InstanceTypes(region string) ([]InstanceType, error) {
+	offerings, err := p.offerings(region)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := p.session(region)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := p.prices(region)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the hardware details of the instance types offered in the region.
+	instances := make([]InstanceType, 0, len(offerings))
+	input := &ec2.DescribeInstanceTypesInput{}
+	err = ec2.New(sess).DescribeInstanceTypesPages(input, func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
+		for _, info := range page.InstanceTypes {
+			name := aws.StringValue(info.InstanceType)
+			if !util.StringInSlice(name, offerings) {
+				continue
+			}
+			instance := InstanceType{
+				Name:         name,
+				PriceHourly:  prices[name],
+				PriceMonthly: prices[name] * HoursPerMonth,
+				Currency:     "USD",
+			}
+			if info.VCpuInfo != nil {
+				instance.CPUs = int(aws.Int64Value(info.VCpuInfo.DefaultVCpus))
+			}
+			if info.MemoryInfo != nil {
+				instance.Memory = float64(aws.Int64Value(info.MemoryInfo.SizeInMiB)) / 1024
+			}
+			instances = append(instances, instance)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Name < instances[j].Name
+	})
+	return instances, nil
 }
 
-func (p providerAws) validateRegionAndInstance(ctx *cli.Context) (string, string, error) {
-	// TODO : use aws api to validate the region and instance type
-	region := strings.ToLower(strings.TrimSpace(ctx.String("aws-region")))
-	instance := strings.ToLower(strings.TrimSpace(ctx.String("aws-instance")))
+// prices returns the hourly on-demand price in USD of running Linux on each
+// instance type in the given region, using the AWS Price List API.
+func (p providerAws) prices(region string) (map[string]float64, error) {
+	// The Price List API is only available in a few regions.
+	sess, err := p.session("us-east-1")
+	if err != nil {
+		return nil, err
+	}
+	filter := func(field, value string) *pricing.Filter {
+		return &pricing.Filter{
+			Type:  aws.String(pricing.FilterTypeTermMatch),
+			Field: aws.String(field),
+			Value: aws.String(value),
+		}
+	}
+	input := &pricing.GetProductsInput{
+		ServiceCode: aws.String("AmazonEC2"),
+		Filters: []*pricing.Filter{
+			filter("regionCode", region),
+			filter("operatingSystem", "Linux"),
+			filter("tenancy", "Shared"),
+			filter("preInstalledSw", "NA"),
+			filter("capacitystatus", "Used"),
+			filter("licenseModel", "No License required"),
+		},
+	}
 
-	if region == "" {
-		region = AllAwsRegions[rand.Intn(len(AllAwsRegions))]
+	// Product of the Price List API which we are interested in.
+	type product struct {
+		Product struct {
+			Attributes struct {
+				InstanceType string `json:"instanceType"`
+			} `json:"attributes"`
+		} `json:"product"`
+		Terms struct {
+			OnDemand map[string]struct {
+				PriceDimensions map[string]struct {
+					PricePerUnit struct {
+						USD string `json:"USD"`
+					} `json:"pricePerUnit"`
+				} `json:"priceDimensions"`
+			} `json:"OnDemand"`
+		} `json:"terms"`
 	}
-	if !util.StringInSlice(region, AllAwsRegions) {
-		return "", "", fmt.Errorf("aws region [%v] is not supported yet", region)
+
+	prices := map[string]float64{}
+	var parseErr error
+	err = pricing.New(sess).GetProductsPages(input, func(page *pricing.GetProductsOutput, lastPage bool) bool {
+		for _, item := range page.PriceList {
+			data, err := json.Marshal(item)
+			if err != nil {
+				parseErr = err
+				return false
+			}
+			var prod product
+			if err := json.Unmarshal(data, &prod); err != nil {
+				parseErr = err
+				return false
+			}
+			for _, term := range prod.Terms.OnDemand {
+				for _, dimension := range term.PriceDimensions {
+					price, err := strconv.ParseFloat(dimension.PricePerUnit.USD, 64)
+					if err == nil && price > 0 {
+						prices[prod.Product.Attributes.InstanceType] = price
+					}
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
+	return prices, parseErr
+}
 
-	if instance == "" {
-		return "", "", fmt.Errorf("instance type [%v] is not supported yet", instance)
+// offerings returns the names of all the instance types offered in the given region.
+func (p providerAws) offerings(region string) ([]string, error) {
+	sess, err := p.session(region)
+	if err != nil {
+		return nil, err
 	}
+	input := &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String(ec2.LocationTypeRegion),
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("location"),
+				Values: []*string{aws.String(region)},
+			},
+		},
+	}
+	instances := make([]string, 0)
+	err = ec2.New(sess).DescribeInstanceTypeOfferingsPages(input, func(page *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
+		for _, offering := range page.InstanceTypeOfferings {
+			instances = append(instances, aws.StringValue(offering.InstanceType))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(instances)
+	return instances, nil
+}
 
-	return region, instance, nil
+// session creates a new AWS session in the given region using the credentials
+// of the provider.
+func (p providerAws) session(region string) (*session.Session, error) {
+	return session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: p.credentials,
+	})
+}
+
+// checkPermissions simulates the IAM policies of the caller to make sure all the
+// actions needed for deploying the darknode are allowed. The check is skipped if
+// the caller is the root user or is not allowed to simulate its own policies.
+func (p providerAws) checkPermissions(region string) error {
+	sess, err := p.session(region)
+	if err != nil {
+		return err
+	}
+	identity, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return err
+	}
+	principal, ok := p.principalArn(sess, aws.StringValue(identity.Arn))
+	if !ok {
+		return nil
+	}
+
+	input := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     aws.StringSlice(AwsActions()),
+	}
+	missing := make([]string, 0)
+	err = iam.New(sess).SimulatePrincipalPolicyPages(input, func(page *iam.SimulatePolicyResponse, lastPage bool) bool {
+		for _, result := range page.EvaluationResults {
+			if aws.StringValue(result.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+				missing = append(missing, aws.StringValue(result.EvalActionName))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "AccessDenied" {
+			color.Yellow("Skip checking the permissions of [%v] as it's not allowed to call iam:SimulatePrincipalPolicy", principal)
+			return nil
+		}
+		return err
+	}
+	if len(missing) > 0 {
+		color.Red("[%v] is missing below permissions for deploying the darknode:", principal)
+		for _, action := range missing {
+			color.Red(action)
+		}
+		return ErrInsufficientPermission
+	}
+	return nil
+}
+
+// principalArn converts the ARN of the caller to the ARN of the IAM user or role
+// which policies can be simulated. It returns false if the caller is the root
+// user or a federated user.
+func (p providerAws) principalArn(sess *session.Session, callerArn string) (string, bool) {
+	caller, err := arn.Parse(callerArn)
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case caller.Service == "iam" && strings.HasPrefix(caller.Resource, "user/"):
+		return callerArn, true
+	case caller.Service == "sts" && strings.HasPrefix(caller.Resource, "assumed-role/"):
+		// The resource is in the form of `assumed-role/{role-name}/{session-name}`
+		parts := strings.Split(caller.Resource, "/")
+		if len(parts) < 2 {
+			return "", false
+		}
+
+		// Roles may have a path which is not included in the ARN of the assumed role.
+		role, err := iam.New(sess).GetRole(&iam.GetRoleInput{RoleName: aws.String(parts[1])})
+		if err == nil {
+			return aws.StringValue(role.Role.Arn), true
+		}
+		return fmt.Sprintf("arn:%v:iam::%v:role/%v", caller.Partition, caller.AccountID, parts[1]), true
+	default:
+		return "", false
+	}
+}
+
+// validateInstanceType validates whether the instance type is offered in the region.
+func validateInstanceType(instance, region string, instances []string) error {
+	if !util.StringInSlice(instance, instances) {
+		fmt.Printf("[%v] is the selected region.\n", region)
+		fmt.Printf("Your account can only create below instance types in [%v]:\n", region)
+		fmt.Println(strings.Join(instances, ", "))
+		fmt.Println("You can find more details about these instance types from https://aws.amazon.com/ec2/instance-types")
+		return ErrInstanceTypeNotAvailable
+	}
+	return nil
 }
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"text/template"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+// awsIPv6Network is the name of the dual-stack VPC and subnet shared by all the
+// darknodes with IPv6 in an AWS region.
+const awsIPv6Network = "darknode-ipv6"
+
+// awsNetworkLocks makes sure the shared network of a region is only set up by
+// one deployment at a time.
+var awsNetworkLocks sync.Map
+
+type awsNetworkTerraform struct {
+	Name   string
+	Region string
+}
+
+// ensureNetwork sets up the dual-stack VPC shared by the darknodes with IPv6 in
+// the region, unless it exists already. The default VPC has no IPv6 range, and
+// a VPC for each darknode would soon reach the quota of 5 VPCs per region. The
+// network is kept when the darknodes are destroyed, it has no cost.
+func (p providerAws) ensureNetwork(region string, quiet bool) error {
+	lock, _ := awsNetworkLocks.LoadOrStore(region, new(sync.Mutex))
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	name := "aws-" + region
+	if err := os.MkdirAll(util.NetworkPath(name), 0700); err != nil {
+		return err
+	}
+	t, err := template.New(NameAws).Parse(awsNetworkTemplate)
+	if err != nil {
+		return err
+	}
+	data := awsNetworkTerraform{Name: awsIPv6Network, Region: region}
+	if err := writeTerraformConfig("networks/"+name, util.NetworkPath(name), t, data); err != nil {
+		return err
+	}
+
+	tf := util.NewNetworkTerraform(name, p.env())
+	if quiet {
+		tf = tf.Quiet()
+	}
+	if err := tf.Init(); err != nil {
+		return err
+	}
+	fmt.Printf("Setting up the shared IPv6 network in [%v] ...\n", region)
+	return tf.Apply()
+}
+
+var awsNetworkTemplate = `
+provider "aws" {
+  region = "{{.Region}}"
+}
+
+resource "aws_vpc" "darknode" {
+  cidr_block                       = "10.0.0.0/16"
+  assign_generated_ipv6_cidr_block = true
+  enable_dns_hostnames             = true
+
+  tags = {
+    Name = "{{.Name}}"
+  }
+}
+
+resource "aws_internet_gateway" "darknode" {
+  vpc_id = aws_vpc.darknode.id
+
+  tags = {
+    Name = "{{.Name}}"
+  }
+}
+
+resource "aws_subnet" "darknode" {
+  vpc_id                          = aws_vpc.darknode.id
+  cidr_block                      = cidrsubnet(aws_vpc.darknode.cidr_block, 8, 1)
+  ipv6_cidr_block                 = cidrsubnet(aws_vpc.darknode.ipv6_cidr_block, 8, 1)
+  map_public_ip_on_launch         = true
+  assign_ipv6_address_on_creation = true
+
+  tags = {
+    Name = "{{.Name}}"
+  }
+}
+
+resource "aws_route_table" "darknode" {
+  vpc_id = aws_vpc.darknode.id
+
+  route {
+    cidr_block = "0.0.0.0/0"
+    gateway_id = aws_internet_gateway.darknode.id
+  }
+
+  route {
+    ipv6_cidr_block = "::/0"
+    gateway_id      = aws_internet_gateway.darknode.id
+  }
+
+  tags = {
+    Name = "{{.Name}}"
+  }
+}
+
+resource "aws_route_table_association" "darknode" {
+  subnet_id      = aws_subnet.darknode.id
+  route_table_id = aws_route_table.darknode.id
+}
+
+output "vpc_id" {
+  value = aws_vpc.darknode.id
+}
+
+output "subnet_id" {
+  value = aws_subnet.darknode.id
+}
+`
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/renproject/darknode-cli/util"
+)
+
+func TestAwsNetwork(t *testing.T) {
+	fake := setupNode(t, "test", "")
+	oldNetworkTerraform := util.NewNetworkTerraform
+	util.NewNetworkTerraform = fake.New
+	t.Cleanup(func() { util.NewNetworkTerraform = oldNetworkTerraform })
+
+	// The network is applied again for every darknode, which does nothing
+	// once it exists.
+	p := providerAws{credentials: credentials.NewStaticCredentials("AKID", "SECRET", "")}
+	for i := 0; i < 2; i++ {
+		if err := p.ensureNetwork("eu-west-1", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(fake.Commands, ",") != "init,apply,init,apply" {
+		t.Fatalf("unexpected commands %v", fake.Commands)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(util.NetworkPath("aws-eu-west-1"), "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`region = "eu-west-1"`, `resource "aws_vpc" "darknode"`, `Name = "darknode-ipv6"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("network main.tf does not contain %q", expected)
+		}
+	}
+
+	// Darknodes look up the shared network instead of creating their own.
+	tmpl, err := template.New(NameAws).Parse(awsTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := new(bytes.Buffer)
+	if err := tmpl.Execute(node, awsTerraform{Name: "test", Region: "eu-west-1", IPv6: true, Network: awsIPv6Network}); err != nil {
+		t.Fatal(err)
+	}
+	if resource := regexp.MustCompile(`resource\s+"aws_(vpc|subnet|internet_gateway)"`).FindString(node.String()); resource != "" {
+		t.Errorf("darknode creates its own network: %v", resource)
+	}
+	for _, expected := range []string{`data "aws_vpc" "darknode"`, `subnet_id              = data.aws_subnet.darknode.id`, `vpc_id      = data.aws_vpc.darknode.id`} {
+		if !strings.Contains(node.String(), expected) {
+			t.Errorf("darknode main.tf does not contain %q", expected)
+		}
+	}
+}
@@ -2,57 +2,67 @@ package provider
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"text/template"
 
 	"github.com/renproject/darknode-cli/util"
 )
 
 type awsTerraform struct {
-	Name          string
-	Region        string
-	InstanceType  string
-	ConfigPath    string
-	PubKeyPath    string
-	PriKeyPath    string
-	AccessKey     string
-	SecretKey     string
-	ServiceFile   string
-	LatestVersion string
+	Name         string
+	Region       string
+	InstanceType string
+	PubKeyPath   string
+	UserDataPath string
+	Image        string
+	ImageName    string
+	IPv6         bool
+	Network      string
+	StaticIP     bool
+	Spot         bool
+	Firewall     string
+	Config       string
 }
 
 // tfConfig generates the terraform config file for deploying to AWS.
-func (p providerAws) tfConfig(name, region, instance, latestVersion string) error {
+func (p providerAws) tfConfig(name, region, instance, latestVersion string, image Image, ipv6, staticIP, spot bool) error {
+	if err := writeUserData(name, latestVersion); err != nil {
+		return err
+	}
+
 	tf := awsTerraform{
-		Name:          name,
-		Region:        region,
-		InstanceType:  instance,
-		ConfigPath:    fmt.Sprintf("~/.darknode/darknodes/%v/config.json", name),
-		PubKeyPath:    fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair.pub", name),
-		PriKeyPath:    fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name),
-		AccessKey:     p.accessKey,
-		SecretKey:     p.secretKey,
-		ServiceFile:   darknodeService,
-		LatestVersion: latestVersion,
+		Name:         name,
+		Region:       region,
+		InstanceType: instance,
+		PubKeyPath:   fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair.pub", name),
+		UserDataPath: fmt.Sprintf("~/.darknode/darknodes/%v/user-data.yaml", name),
+		Image:        image.Name,
+		ImageName:    image.Aws,
+		IPv6:         ipv6,
+		Network:      awsIPv6Network,
+		StaticIP:     staticIP,
+		Spot:         spot,
+		Firewall:     sshVariable + ufwResource("aws_instance.darknode.id", awsHost(staticIP), `"ubuntu"`, fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name)),
+		Config:       configResource("aws_instance.darknode.id", awsHost(staticIP), name),
 	}
 
-	t, err := template.New("aws").Parse(awsTemplate)
+	t, err := template.New(NameAws).Parse(awsTemplate)
 	if err != nil {
 		return err
 	}
-	tfFile, err := os.Create(filepath.Join(util.NodePath(name), "main.tf"))
-	if err != nil {
-		return err
+	return writeTerraformConfig(name, util.NodePath(name), t, tf)
+}
+
+// awsHost returns the terraform expression of the public IP of the instance.
+func awsHost(staticIP bool) string {
+	if staticIP {
+		return "aws_eip.darknode.public_ip"
 	}
-	return t.Execute(tfFile, tf)
+	return "aws_instance.darknode.public_ip"
 }
 
 var awsTemplate = `
 provider "aws" {
   region     = "{{.Region}}"
-  access_key = "{{.AccessKey}}"
-  secret_key = "{{.SecretKey}}"
 }
 
 data "aws_ami" "ubuntu" {
@@ -60,7 +70,7 @@ data "aws_ami" "ubuntu" {
 
   filter {
     name   = "name"
-    values = ["ubuntu/images/hvm-ssd/ubuntu-bionic-18.04-amd64-server-*"]
+    values = ["{{.ImageName}}"]
   }
 
   filter {
@@ -70,17 +80,46 @@ data "aws_ami" "ubuntu" {
 
   owners = ["099720109477"] # Canonical
 }
+{{if .IPv6}}
+// The dual-stack VPC shared by all the darknodes with IPv6 in the region.
+data "aws_vpc" "darknode" {
+  tags = {
+    Name = "{{.Network}}"
+  }
+}
 
+data "aws_subnet" "darknode" {
+  vpc_id = data.aws_vpc.darknode.id
+
+  tags = {
+    Name = "{{.Network}}"
+  }
+}
+{{end}}
 resource "aws_security_group" "darknode" {
   name        = "darknode-sg-{{.Name}}"
-  description = "Allow inbound SSH and REN project traffic"
+  description = "Allow inbound SSH and REN project traffic"{{if .IPv6}}
+  vpc_id      = data.aws_vpc.darknode.id{{end}}
 
   // SSH
-  ingress {
-    from_port   = 22
-    to_port     = 22
-    protocol    = "tcp"
-    cidr_blocks = ["0.0.0.0/0"]
+  dynamic "ingress" {
+    for_each = length(local.ssh_cidrs_ipv4) > 0 ? [local.ssh_cidrs_ipv4] : []
+    content {
+      from_port   = 22
+      to_port     = 22
+      protocol    = "tcp"
+      cidr_blocks = ingress.value
+    }
+  }
+
+  dynamic "ingress" {
+    for_each = length(local.ssh_cidrs_ipv6) > 0 ? [local.ssh_cidrs_ipv6] : []
+    content {
+      from_port        = 22
+      to_port          = 22
+      protocol         = "tcp"
+      ipv6_cidr_blocks = ingress.value
+    }
   }
 
   // ren project
@@ -88,14 +127,16 @@ resource "aws_security_group" "darknode" {
     from_port   = 18514
     to_port     = 18515
     protocol    = "tcp"
-    cidr_blocks = ["0.0.0.0/0"]
+    cidr_blocks = ["0.0.0.0/0"]{{if .IPv6}}
+    ipv6_cidr_blocks = ["::/0"]{{end}}
   }
 
   egress {
     from_port   = 0
     to_port     = 0
     protocol    = "-1"
-    cidr_blocks = ["0.0.0.0/0"]
+    cidr_blocks = ["0.0.0.0/0"]{{if .IPv6}}
+    ipv6_cidr_blocks = ["::/0"]{{end}}
   }
 }
 
@@ -108,90 +149,61 @@ resource "aws_instance" "darknode" {
   ami             = data.aws_ami.ubuntu.id
   instance_type   = "{{.InstanceType}}"
   key_name        = aws_key_pair.darknode.key_name
-  security_groups = [aws_security_group.darknode.name]
   monitoring      = true 
-
+  user_data       = file("{{.UserDataPath}}")
+{{if .Spot}}
+  // One-time spot request, the instance is terminated when it's reclaimed.
+  instance_market_options {
+    market_type = "spot"
+  }
+{{end}}{{if .IPv6}}
+  subnet_id              = data.aws_subnet.darknode.id
+  vpc_security_group_ids = [aws_security_group.darknode.id]
+  ipv6_address_count     = 1
+{{else}}
+  security_groups = [aws_security_group.darknode.name]
+{{end}}
   tags = {
     Name = "{{.Name}}"
   }
 
-  provisioner "remote-exec" {
-
-	inline = [
-      "set -x",
-      "until sudo apt update; do sleep 4; done",
-      "sudo adduser darknode --gecos \",,,\" --disabled-password",
-      "sudo rsync --archive --chown=darknode:darknode ~/.ssh /home/darknode",
-      "sudo DEBIAN_FRONTEND=noninteractive apt-get -y update",
-      "sudo DEBIAN_FRONTEND=noninteractive apt-get -y upgrade",
-      "sudo DEBIAN_FRONTEND=noninteractive apt-get -y dist-upgrade",
-      "sudo DEBIAN_FRONTEND=noninteractive apt-get -y autoremove",
-      "sudo apt-get install ufw",
-      "sudo ufw limit 22/tcp",
-      "sudo ufw allow 18514/tcp", 
-      "sudo ufw allow 18515/tcp", 
-      "sudo ufw --force enable",
-	]
-
-    connection {
-      host        = coalesce(self.public_ip, self.private_ip)
-      type        = "ssh"
-      user        = "ubuntu"
-      private_key = file("{{.PriKeyPath}}")
-    }
+  // A newer Ubuntu AMI must not replace a running darknode.
+  lifecycle {
+    ignore_changes = [ami]
   }
+}
 
-  provisioner "file" {
-
-    source      = "{{.ConfigPath}}"
-    destination = "$HOME/config.json"
-
-    connection {
-      host        = coalesce(self.public_ip, self.private_ip)
-      type        = "ssh"
-      user        = "darknode"
-      private_key = file("{{.PriKeyPath}}")
-    }
-  }
+{{if .StaticIP}}
+resource "aws_eip" "darknode" {
+  domain   = "vpc"
+  instance = aws_instance.darknode.id
 
-  provisioner "remote-exec" {
-	
-	inline = [
-      "set -x",
-	  "mkdir -p $HOME/.darknode/bin",
-      "mkdir -p $HOME/.config/systemd/user",
-      "mv $HOME/config.json $HOME/.darknode/config.json",
-	  "curl -sL https://www.github.com/renproject/darknode-release/releases/download/{{.LatestVersion}}/darknode > ~/.darknode/bin/darknode",
-	  "chmod +x ~/.darknode/bin/darknode",
-      "echo {{.LatestVersion}} > ~/.darknode/version",
-	  <<EOT
-	  echo "{{.ServiceFile}}" > ~/.config/systemd/user/darknode.service
-      EOT
-      ,
-	  "loginctl enable-linger darknode",
-      "systemctl --user enable darknode.service",
-      "systemctl --user start darknode.service",
-	]
-
-    connection {
-      host        = coalesce(self.public_ip, self.private_ip)
-      type        = "ssh"
-      user        = "darknode"
-      private_key = file("{{.PriKeyPath}}")
-    }
+  tags = {
+    Name = "{{.Name}}"
   }
 }
-
+{{end}}
+{{.Firewall}}{{.Config}}
 output "provider" {
   value = "aws"
 }
 
+output "image" {
+  value = "{{.Image}}"
+}
+
+output "spot" {
+  value = {{.Spot}}
+}
+
 output "ip" {
+{{- if .StaticIP}}
+  value = aws_eip.darknode.public_ip
+{{- else}}
   value = aws_instance.darknode.public_ip
-}`
-
-// {{if .AllocationID}}
-// resource "aws_eip_association" "eip_assoc" {
-// instance_id   = "${aws_instance.darknode.id}"
-// allocation_id = "${var.allocation_id}"
-// }{{else}}{{end}}
+{{- end}}
+}
+{{if .IPv6}}
+output "ipv6" {
+  value = aws_instance.darknode.ipv6_addresses[0]
+}{{end}}`
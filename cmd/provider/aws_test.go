@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func awsContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, name := range []string{"aws-access-key", "aws-secret-key", "aws-session-token", "aws-profile"} {
+		set.String(name, "", "")
+	}
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+// awsFiles writes the shared config and credentials files of the AWS SDK to a
+// temporary directory and points the SDK to them.
+func awsFiles(t *testing.T, config, credentials string) {
+	dir, err := ioutil.TempDir("", "darknode-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for key, file := range map[string]string{
+		"AWS_CONFIG_FILE":             "config",
+		"AWS_SHARED_CREDENTIALS_FILE": "credentials",
+	} {
+		key, path := key, filepath.Join(dir, file)
+		content := config
+		if file == "credentials" {
+			content = credentials
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		old, ok := os.LookupEnv(key)
+		os.Setenv(key, path)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+func TestAwsEnv(t *testing.T) {
+	awsFiles(t, "", "[dev]\naws_access_key_id = AKIDPROFILE\naws_secret_access_key = SECRETPROFILE\n")
+
+	// Credentials of a profile are resolved by terraform itself.
+	p, err := NewAws(awsContext(t, "--aws-profile", "dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := p.(providerAws).env(); !reflect.DeepEqual(env, []string{"AWS_PROFILE=dev"}) {
+		t.Errorf("unexpected env of profile credentials, got %v", env)
+	}
+
+	// Credentials given by flags are passed as they are.
+	p, err = NewAws(awsContext(t, "--aws-access-key", "AKID", "--aws-secret-key", "SECRET", "--aws-session-token", "TOKEN", "--aws-profile", "dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"AWS_ACCESS_KEY_ID=AKID", "AWS_SECRET_ACCESS_KEY=SECRET", "AWS_SESSION_TOKEN=TOKEN"}
+	if env := p.(providerAws).env(); !reflect.DeepEqual(env, expected) {
+		t.Errorf("unexpected env of static credentials, got %v", env)
+	}
+}
+
+func TestAwsUnattended(t *testing.T) {
+	awsFiles(t,
+		"[profile base]\n[profile mfa]\nrole_arn = arn:aws:iam::123456789012:role/darknode\nsource_profile = base\nmfa_serial = arn:aws:iam::123456789012:mfa/user\n",
+		"[base]\naws_access_key_id = AKIDBASE\naws_secret_access_key = SECRETBASE\n")
+	oldTokenProvider := mfaTokenProvider
+	defer func() { mfaTokenProvider = oldTokenProvider }()
+
+	// The MFA code is never prompted when running unattended.
+	RunUnattended()
+	if _, err := NewAws(awsContext(t, "--aws-profile", "mfa")); err == nil || !strings.Contains(err.Error(), ErrMFAUnattended.Error()) {
+		t.Fatalf("expected %v, got %v", ErrMFAUnattended, err)
+	}
+}
+
+func TestFromProfile(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"SharedCredentialsProvider":                  true,
+		"SharedConfigCredentials: /root/.aws/config": true,
+		"AssumeRoleProvider":                         true,
+		"SSOProvider":                                true,
+		"ProcessProvider":                            true,
+		"StaticProvider":                             false,
+		"EnvProvider":                                false,
+		"EC2RoleProvider":                            false,
+	} {
+		if fromProfile(name) != expected {
+			t.Errorf("fromProfile(%q) should be %v", name, expected)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+// backendTemplate configures terraform to keep the state of the darknode in the
+// remote backend, so that it can be shared by everyone who operates the
+// darknode. The state is locked with a DynamoDB table or a lock file for S3,
+// GCS locks the state natively.
+var backendTemplate = `terraform {
+{{- if eq .Type "s3"}}
+  backend "s3" {
+    bucket = "{{.Bucket}}"
+    key    = "{{.Prefix}}/{{.Name}}/terraform.tfstate"
+    region = "{{.Region}}"
+    {{- if .Profile}}
+    profile = "{{.Profile}}"
+    {{- end}}
+    {{- if .DynamoDBTable}}
+    dynamodb_table = "{{.DynamoDBTable}}"
+    {{- else}}
+    use_lockfile = true
+    {{- end}}
+    {{- if .Endpoint}}
+    endpoints = {
+      s3 = "{{.Endpoint}}"
+    }
+    use_path_style              = true
+    skip_credentials_validation = true
+    skip_region_validation      = true
+    skip_requesting_account_id  = true
+    skip_metadata_api_check     = true
+    {{- end}}
+  }
+{{- else}}
+  backend "gcs" {
+    bucket = "{{.Bucket}}"
+    prefix = "{{.Prefix}}/{{.Name}}"
+    {{- if .Credentials}}
+    credentials = "{{.Credentials}}"
+    {{- end}}
+  }
+{{- end}}
+}
+`
+
+// backendConfig returns the terraform backend block of the darknode, or an
+// empty string if no backend is configured.
+func backendConfig(name string) (string, error) {
+	settings, err := util.ReadSettings()
+	if err != nil || settings.Backend == nil {
+		return "", err
+	}
+
+	t, err := template.New("backend").Parse(backendTemplate)
+	if err != nil {
+		return "", err
+	}
+	backend := new(bytes.Buffer)
+	err = t.Execute(backend, struct {
+		util.Backend
+		Name string
+	}{*settings.Backend, name})
+	return backend.String(), err
+}
+
+// hasBackend returns whether the terraform config has a backend block.
+func hasBackend(tf []byte) bool {
+	return bytes.Contains(tf, []byte(`backend "`))
+}
+
+// MigrateState moves the local terraform state of the darknode to the backend
+// configured in the settings file. It returns whether the state has been moved.
+// Nothing is changed if no backend is configured or the darknode already uses
+// a backend.
+func MigrateState(name string) (bool, error) {
+	backend, err := backendConfig(name)
+	if err != nil || backend == "" {
+		return false, err
+	}
+	path := filepath.Join(util.NodePath(name), "main.tf")
+	tf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if hasBackend(tf) {
+		return false, nil
+	}
+
+	if err := ioutil.WriteFile(path, append([]byte(backend), tf...), 0600); err != nil {
+		return false, err
+	}
+	if err := util.NewTerraform(name, nil).Init("-migrate-state", "-force-copy"); err != nil {
+		// revert the `main.tf` file if fail to migrate the state
+		if err := ioutil.WriteFile(path, tf, 0600); err != nil {
+			fmt.Println("fail to revert the change to `main.tf` file")
+		}
+		return false, err
+	}
+
+	// Terraform leaves a copy of the old state behind, rename it so no one
+	// mistakes it for the current state.
+	for _, file := range []string{"terraform.tfstate", "terraform.tfstate.backup"} {
+		old := filepath.Join(util.NodePath(name), file)
+		if _, err := os.Stat(old); err != nil {
+			continue
+		}
+		renamed := filepath.Join(util.NodePath(name), strings.Replace(file, "terraform", "migrated", 1))
+		if err := os.Rename(old, renamed); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
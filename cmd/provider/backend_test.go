@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+func TestInstanceWithBackend(t *testing.T) {
+	setupNode(t, "legacy-aws", "")
+	copyFixture(t, "legacy-aws", "legacy-aws")
+	settings := `{"backend": {"type": "s3", "bucket": "darknodes", "region": "us-east-1"}}`
+	if err := ioutil.WriteFile(util.SettingsPath(), []byte(settings), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if migrated, err := MigrateState("legacy-aws"); err != nil || !migrated {
+		t.Fatalf("cannot migrate the state, migrated = %v, err = %v", migrated, err)
+	}
+
+	// The region of the bucket comes before the one of the darknode.
+	region, instance, err := providerAws{}.Instance("legacy-aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region != "eu-west-1" || instance != "t3.micro" {
+		t.Fatalf("expected eu-west-1 and t3.micro, got %v and %v", region, instance)
+	}
+
+	// The metadata is used once the darknode has it.
+	if err := util.WriteMetadata(util.Metadata{Name: "legacy-aws", Region: "ap-south-1", Instance: "t3.small"}); err != nil {
+		t.Fatal(err)
+	}
+	region, instance, err = providerAws{}.Instance("legacy-aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region != "ap-south-1" || instance != "t3.small" {
+		t.Fatalf("expected ap-south-1 and t3.small, got %v and %v", region, instance)
+	}
+}
+
+func TestInstanceRegexes(t *testing.T) {
+	const backend = `terraform {
+  backend "s3" {
+    bucket = "darknodes"
+    region = "us-east-1"
+  }
+}
+`
+	tests := []struct {
+		name                 string
+		tf                   string
+		regionRe, instanceRe string
+		region, instance     string
+	}{
+		{
+			"do",
+			backend + `provider "digitalocean" {}
+
+resource "digitalocean_droplet" "darknode" {
+  image  = "ubuntu-22-04-x64"
+  name   = "test"
+  region = "nyc1"
+  size   = "s-1vcpu-1gb"
+}
+`, RegexDoRegion, RegexDo, "nyc1", "s-1vcpu-1gb",
+		},
+		{
+			"gcp",
+			`provider "google" {
+  project = "darknodes"
+  zone    = "us-east1-b"
+}
+
+resource "google_compute_instance" "darknode" {
+  machine_type = "e2-small"
+}
+`, RegexGcpZone, RegexGcp, "us-east1-b", "e2-small",
+		},
+		{
+			"hetzner",
+			backend + `resource "hcloud_server" "darknode" {
+  server_type = "cx22"
+  location    = "fsn1"
+}
+`, RegexHetznerLocation, RegexHetzner, "fsn1", "cx22",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupNode(t, "test", test.tf)
+			region, instance, err := instanceTerraform("test", test.regionRe, test.instanceRe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if region != test.region || instance != test.instance {
+				t.Fatalf("expected %v and %v, got %v and %v", test.region, test.instance, region, instance)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+// CatalogTTL is how long the cached catalog of a provider stays valid.
+var CatalogTTL = 24 * time.Hour
+
+// HoursPerMonth is used for estimating the monthly price from the hourly price.
+const HoursPerMonth = 730
+
+// InstanceType describes the hardware and price of an instance type offered by
+// the provider. Prices are zero if the provider API doesn't give them.
+type InstanceType struct {
+	Name         string  `json:"name"`
+	CPUs         int     `json:"cpus"`
+	Memory       float64 `json:"memory"` // in GiB
+	PriceHourly  float64 `json:"priceHourly,omitempty"`
+	PriceMonthly float64 `json:"priceMonthly,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+}
+
+// instanceTypeNames returns the names of the instance types.
+func instanceTypeNames(instances []InstanceType) []string {
+	names := make([]string, len(instances))
+	for i := range instances {
+		names[i] = instances[i].Name
+	}
+	return names
+}
+
+// catalog is the cached catalog of a provider which is stored in the cache
+// directory.
+type catalog struct {
+	Timestamp     time.Time      `json:"timestamp"`
+	Regions       []string       `json:"regions,omitempty"`
+	InstanceTypes []InstanceType `json:"instanceTypes,omitempty"`
+}
+
+// CatalogRegions returns the regions of the provider from the local cache, or
+// fetches them from the provider API if the cache is missing or expired.
+func CatalogRegions(p Provider, refresh bool) ([]string, error) {
+	path := catalogPath(p.Name(), "regions")
+	if !refresh {
+		if cache, err := readCatalog(path); err == nil && time.Since(cache.Timestamp) < CatalogTTL {
+			return cache.Regions, nil
+		}
+	}
+
+	regions, err := p.Regions()
+	if err != nil {
+		return nil, err
+	}
+	return regions, writeCatalog(path, catalog{Timestamp: time.Now(), Regions: regions})
+}
+
+// CatalogInstanceTypes returns the instance types of the provider in the given
+// region from the local cache, or fetches them from the provider API if the
+// cache is missing or expired.
+func CatalogInstanceTypes(p Provider, region string, refresh bool) ([]InstanceType, error) {
+	path := catalogPath(p.Name(), region)
+	if !refresh {
+		if cache, err := readCatalog(path); err == nil && time.Since(cache.Timestamp) < CatalogTTL {
+			return cache.InstanceTypes, nil
+		}
+	}
+
+	instances, err := p.InstanceTypes(region)
+	if err != nil {
+		return nil, err
+	}
+	return instances, writeCatalog(path, catalog{Timestamp: time.Now(), InstanceTypes: instances})
+}
+
+// CachedInstanceType returns the instance type from the local cache of the
+// provider catalog, no matter whether the cache has expired.
+func CachedInstanceType(provider, region, name string) (InstanceType, bool) {
+	cache, err := readCatalog(catalogPath(provider, region))
+	if err != nil {
+		return InstanceType{}, false
+	}
+	for _, instance := range cache.InstanceTypes {
+		if instance.Name == name {
+			return instance, true
+		}
+	}
+	return InstanceType{}, false
+}
+
+func catalogPath(provider, name string) string {
+	return filepath.Join(util.Directory, "cache", fmt.Sprintf("%v-%v.json", provider, name))
+}
+
+func readCatalog(path string) (catalog, error) {
+	var cache catalog
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cache, err
+	}
+	err = json.Unmarshal(data, &cache)
+	return cache, err
+}
+
+func writeCatalog(path string, cache catalog) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,271 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/util"
+)
+
+// ReadyTimeout is how long `up` waits for the darknode to finish provisioning.
+var ReadyTimeout = 20 * time.Minute
+
+// ErrNotReady is returned when the darknode doesn't finish provisioning before
+// ReadyTimeout.
+var ErrNotReady = errors.New("timeout waiting for the darknode to finish provisioning")
+
+type bootstrap struct {
+	Version  string
+	URL      string
+	Checksum string
+	PubKey   string
+}
+
+// bootstrapScript sets up the darknode on a fresh Ubuntu server. It runs as root
+// with the directory containing `darknode.service` as its first argument. It's
+// run by cloud-init on cloud providers, and by the provisioners on existing
+// servers and in docker containers, where the second argument is `existing` or
+// `container`, so that all darknodes are set up in the same way. The keystore is never part of the
+// user-data, `config.json` is either in the same directory or copied to
+// ~/.darknode over SSH once the darknode user exists. The darknode user can
+// check the result by looking for the `ready` or `failed` marker in ~/.darknode.
+var bootstrapScript = `#!/bin/bash
+set -euo pipefail
+
+src="$1"
+mode="${2:-server}"
+home=/home/darknode
+trap 'mkdir -p $home/.darknode && echo "bootstrap failed at line $LINENO" > $home/.darknode/failed' ERR
+
+# Docker images come with the packages and have no firewall or systemd, the
+# firewall of existing servers is left to their owner.
+export DEBIAN_FRONTEND=noninteractive
+if [ "$mode" = server ]; then
+  apt-get -o DPkg::Lock::Timeout=600 update
+  apt-get -o DPkg::Lock::Timeout=600 install -y curl ufw
+elif [ "$mode" = existing ] && ! command -v curl > /dev/null; then
+  apt-get -o DPkg::Lock::Timeout=600 update
+  apt-get -o DPkg::Lock::Timeout=600 install -y curl
+fi
+
+# Darknode user which is only accessible with the ssh key of the darknode
+id -u darknode > /dev/null 2>&1 || adduser darknode --gecos ",,," --disabled-password
+rm -f $home/.darknode/ready $home/.darknode/failed
+install -d -m 700 -o darknode -g darknode $home/.ssh $home/.darknode $home/.darknode/bin
+echo "{{.PubKey}}" > $home/.ssh/authorized_keys
+chmod 600 $home/.ssh/authorized_keys
+
+# Firewall. It's never enabled on existing servers, where it could cut off the
+# other services, the darknode ports are only opened if it's active already.
+if [ "$mode" = server ]; then
+  ufw limit 22/tcp
+  ufw allow 18514/tcp
+  ufw allow 18515/tcp
+  ufw --force enable
+elif [ "$mode" = existing ] && command -v ufw > /dev/null && ufw status | grep -q "Status: active"; then
+  ufw allow 18514/tcp
+  ufw allow 18515/tcp
+fi
+
+# Config, which is copied over SSH if it's not given with the service file
+if [ -f $src/config.json ]; then
+  install -m 600 $src/config.json $home/.darknode/config.json
+  rm -f $src/config.json
+fi
+for i in $(seq 240); do
+  [ -f $home/.darknode/config.json ] && break
+  sleep 5
+done
+if [ ! -f $home/.darknode/config.json ]; then
+  echo "config.json has not been copied to the darknode" > $home/.darknode/failed
+  exit 1
+fi
+
+# Service file
+install -d $home/.config/systemd/user
+install -m 644 $src/darknode.service $home/.config/systemd/user/darknode.service
+rm -f $src/darknode.service
+
+# Darknode binary
+curl -sSfL --retry 5 {{.URL}} -o $home/.darknode/bin/darknode.download
+echo "{{.Checksum}}  $home/.darknode/bin/darknode.download" | sha256sum -c -
+mv $home/.darknode/bin/darknode.download $home/.darknode/bin/darknode
+chmod 755 $home/.darknode/bin/darknode
+echo {{.Version}} > $home/.darknode/version
+chown -R darknode:darknode $home
+
+# Start the service in the systemd user instance of the darknode user
+if [ "$mode" = container ]; then
+  su darknode -c "systemctl --user enable darknode.service && systemctl --user start darknode.service"
+else
+  loginctl enable-linger darknode
+  uid=$(id -u darknode)
+  systemctl start user@$uid.service
+  runuser -u darknode -- env XDG_RUNTIME_DIR=/run/user/$uid systemctl --user daemon-reload
+  runuser -u darknode -- env XDG_RUNTIME_DIR=/run/user/$uid systemctl --user enable --now darknode.service
+fi
+
+touch $home/.darknode/ready
+chown darknode:darknode $home/.darknode/ready
+`
+
+// userDataTemplate is the cloud-init user-data which writes the files needed by
+// the bootstrap script and runs it. It has nothing secret, since it can be read
+// from the instance metadata and the provider console.
+var userDataTemplate = `#cloud-config
+package_update: true
+package_upgrade: true
+write_files:
+  - path: /var/lib/darknode/darknode.service
+    permissions: '0644'
+    encoding: b64
+    content: {{.Service}}
+  - path: /var/lib/darknode/bootstrap.sh
+    permissions: '0700'
+    encoding: b64
+    content: {{.Script}}
+runcmd:
+  - [/var/lib/darknode/bootstrap.sh, /var/lib/darknode]
+`
+
+// releaseChecksum returns the published checksum of the darknode release. It
+// can be replaced in tests.
+var releaseChecksum = util.ReleaseChecksum
+
+// serviceFile returns the systemd unit of the darknode with the home directory
+// of the darknode user.
+func serviceFile() string {
+	return strings.Replace(darknodeService, "$HOME", "/home/darknode", -1)
+}
+
+// renderBootstrapScript returns the bootstrap script which installs the given
+// version of the darknode.
+func renderBootstrapScript(name, version string) (string, error) {
+	checksum, err := releaseChecksum(version)
+	if err != nil {
+		return "", err
+	}
+	pubKey, err := ioutil.ReadFile(filepath.Join(util.NodePath(name), "ssh_keypair.pub"))
+	if err != nil {
+		return "", err
+	}
+
+	t, err := template.New("bootstrap").Parse(bootstrapScript)
+	if err != nil {
+		return "", err
+	}
+	script := new(bytes.Buffer)
+	err = t.Execute(script, bootstrap{
+		Version:  version,
+		URL:      util.ReleaseURL(version),
+		Checksum: checksum,
+		PubKey:   strings.TrimSpace(string(pubKey)),
+	})
+	return script.String(), err
+}
+
+// writeUserData generates the cloud-init user-data of the darknode in the node
+// directory. The config of the darknode is copied by configResource instead.
+func writeUserData(name, version string) error {
+	script, err := renderBootstrapScript(name, version)
+	if err != nil {
+		return err
+	}
+
+	t, err := template.New("user-data").Parse(userDataTemplate)
+	if err != nil {
+		return err
+	}
+	userData := new(bytes.Buffer)
+	err = t.Execute(userData, map[string]string{
+		"Service": base64.StdEncoding.EncodeToString([]byte(serviceFile())),
+		"Script":  base64.StdEncoding.EncodeToString([]byte(script)),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(util.NodePath(name), "user-data.yaml"), userData.Bytes(), 0600)
+}
+
+// configResource returns a terraform resource which copies the config of the
+// darknode over SSH once the bootstrap script has created the darknode user, so
+// the keystore is never in the user-data, the instance metadata or the
+// terraform state. It's copied again whenever the server is replaced.
+func configResource(server, host, name string) string {
+	connection := fmt.Sprintf(`
+    connection {
+      host        = %v
+      type        = "ssh"
+      user        = "darknode"
+      private_key = file("~/.darknode/darknodes/%v/ssh_keypair")
+      timeout     = "20m"
+    }`, host, name)
+
+	return fmt.Sprintf(`
+resource "null_resource" "config" {
+
+  triggers = {
+    server = %v
+  }
+
+  provisioner "file" {
+
+    source      = "~/.darknode/darknodes/%v/config.json"
+    destination = "/home/darknode/.darknode/config.json.upload"
+%v
+  }
+
+  provisioner "remote-exec" {
+
+    inline = [
+      "chmod 600 ~/.darknode/config.json.upload",
+      "mv ~/.darknode/config.json.upload ~/.darknode/config.json",
+    ]
+%v
+  }
+}
+`, server, name, connection, connection)
+}
+
+// writeBootstrapFiles writes the bootstrap script and the systemd unit to the
+// node directory, for servers which are not provisioned by cloud-init.
+func writeBootstrapFiles(name, version string) error {
+	script, err := renderBootstrapScript(name, version)
+	if err != nil {
+		return err
+	}
+	path := util.NodePath(name)
+	if err := ioutil.WriteFile(filepath.Join(path, "bootstrap.sh"), []byte(script), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(path, "darknode.service"), []byte(serviceFile()), 0600)
+}
+
+// waitForReady waits until the bootstrap script has finished on the darknode.
+func waitForReady(name string) error {
+	color.Green("Waiting for darknode [%v] to finish provisioning ...", name)
+	script := "if [ -f ~/.darknode/ready ]; then echo ready; elif [ -f ~/.darknode/failed ]; then cat ~/.darknode/failed; fi"
+	deadline := time.Now().Add(ReadyTimeout)
+	for time.Now().Before(deadline) {
+		output, err := util.RemoteOutput(name, script)
+		if err == nil {
+			status := strings.TrimSpace(string(output))
+			if status == "ready" {
+				return nil
+			}
+			if status != "" {
+				return fmt.Errorf("fail to provision darknode [%v]: %v", name, status)
+			}
+		}
+		time.Sleep(10 * time.Second)
+	}
+	return ErrNotReady
+}
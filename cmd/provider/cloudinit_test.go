@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+const testChecksum = "6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"
+
+// stubRelease writes the ssh key of the test node and replaces the published
+// checksum of the release, so no release needs to be downloaded.
+func stubRelease(t *testing.T) {
+	oldChecksum := releaseChecksum
+	releaseChecksum = func(version string) (string, error) { return testChecksum, nil }
+	t.Cleanup(func() { releaseChecksum = oldChecksum })
+
+	if err := ioutil.WriteFile(filepath.Join(util.NodePath("test"), "ssh_keypair.pub"), []byte("ssh-ed25519 AAAA test\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUserData(t *testing.T) {
+	setupNode(t, "test", "")
+	stubRelease(t)
+	const secret = `{"keystore": "secret"}`
+	if err := ioutil.WriteFile(filepath.Join(util.NodePath("test"), "config.json"), []byte(secret), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeUserData("test", "0.4.0"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(util.NodePath("test"), "user-data.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	userData := string(data)
+
+	// The user-data can be read from the instance metadata, so it must not
+	// have the keystore in any form.
+	for _, content := range regexp.MustCompile(`content: (\S+)`).FindAllStringSubmatch(userData, -1) {
+		decoded, err := base64.StdEncoding.DecodeString(content[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		userData += string(decoded)
+	}
+	if strings.Contains(userData, "config.json\n    permissions") || strings.Contains(userData, "keystore") {
+		t.Fatalf("user-data contains the config of the darknode:\n%v", userData)
+	}
+	for _, expected := range []string{
+		`curl -sSfL --retry 5 ` + util.ReleaseURL("0.4.0"),
+		`echo "` + testChecksum + `  $home/.darknode/bin/darknode.download" | sha256sum -c -`,
+		`[ -f $home/.darknode/config.json ] && break`,
+	} {
+		if !strings.Contains(userData, expected) {
+			t.Errorf("user-data does not contain %q", expected)
+		}
+	}
+}
+
+func TestConfigResource(t *testing.T) {
+	resource := configResource("aws_instance.darknode.id", "aws_instance.darknode.public_ip", "test")
+	for _, expected := range []string{
+		`server = aws_instance.darknode.id`,
+		`source      = "~/.darknode/darknodes/test/config.json"`,
+		`host        = aws_instance.darknode.public_ip`,
+		`user        = "darknode"`,
+		`mv ~/.darknode/config.json.upload ~/.darknode/config.json`,
+	} {
+		if !strings.Contains(resource, expected) {
+			t.Errorf("config resource does not contain %q", expected)
+		}
+	}
+	// Only the path of the config is in the terraform config, so the keystore
+	// is not kept in the state.
+	if strings.Contains(resource, "file(\"~/.darknode/darknodes/test/config.json\")") {
+		t.Error("config is read into the terraform config")
+	}
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+// Regex for the credentials which were written to the provider blocks of the
+// terraform config by older versions of the CLI. They only match whole lines,
+// so nothing else is removed from the config.
+var regexCredentials = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^[ \t]*access_key[ \t]*=[ \t]*"(.*)"[ \t]*\n`),
+	regexp.MustCompile(`(?m)^[ \t]*secret_key[ \t]*=[ \t]*"(.*)"[ \t]*\n`),
+	regexp.MustCompile(`(?m)^[ \t]*token[ \t]*=[ \t]*"(.*)"[ \t]*\n`),
+	regexp.MustCompile(`(?m)^[ \t]*credentials[ \t]*=[ \t]*file\("(.*)"\)[ \t]*\n`),
+}
+
+// regexProviderBlock matches the start of a provider block in the terraform
+// config.
+var regexProviderBlock = regexp.MustCompile(`(?m)^provider\s+"[^"]+"\s*\{`)
+
+// scrubProviderBlocks removes the credentials from the provider blocks of the
+// terraform config and returns the new config with the removed secrets.
+func scrubProviderBlocks(tf []byte) ([]byte, [][]byte) {
+	scrubbed := make([]byte, 0, len(tf))
+	secrets := make([][]byte, 0)
+	for {
+		loc := regexProviderBlock.FindIndex(tf)
+		if loc == nil {
+			return append(scrubbed, tf...), secrets
+		}
+		end := blockEnd(tf, loc[1])
+		block := tf[loc[0]:end]
+		for _, reg := range regexCredentials {
+			for _, match := range reg.FindAllSubmatch(block, -1) {
+				if len(match[1]) > 0 {
+					secrets = append(secrets, match[1])
+				}
+			}
+			block = reg.ReplaceAll(block, nil)
+		}
+		scrubbed = append(append(scrubbed, tf[:loc[0]]...), block...)
+		tf = tf[end:]
+	}
+}
+
+// blockEnd returns the index after the closing brace of the block whose
+// opening brace is right before the given start, or the end of the config if
+// the block is not closed.
+func blockEnd(tf []byte, start int) int {
+	depth := 1
+	for i := start; i < len(tf); i++ {
+		switch tf[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(tf)
+}
+
+// ScrubCredentials removes the cloud credentials from the terraform config and
+// the terraform state files of the darknode. It returns whether any credentials
+// have been found. The credentials need to be given to the CLI when running
+// commands which talk to the provider afterwards.
+func ScrubCredentials(name string) (bool, error) {
+	path := filepath.Join(util.NodePath(name), "main.tf")
+	tf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	// Find and remove the credentials from the terraform config.
+	tf, secrets := scrubProviderBlocks(tf)
+	if len(secrets) == 0 {
+		return false, nil
+	}
+	if err := ioutil.WriteFile(path, tf, 0600); err != nil {
+		return false, err
+	}
+
+	// Remove any copy of the credentials from the state files and their backups.
+	files, err := ioutil.ReadDir(util.NodePath(name))
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), "terraform.tfstate") {
+			continue
+		}
+		statePath := filepath.Join(util.NodePath(name), file.Name())
+		state, err := ioutil.ReadFile(statePath)
+		if err != nil {
+			return false, err
+		}
+		for _, secret := range secrets {
+			state = bytes.Replace(state, secret, nil, -1)
+		}
+		if err := ioutil.WriteFile(statePath, state, 0600); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
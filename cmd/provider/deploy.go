@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/renproject/darknode-cli/darknode"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/urfave/cli"
+)
+
+// ErrConfigForManyNodes is returned when user gives a config file for
+// deploying more than one darknode, which would make them share the keystore.
+var ErrConfigForManyNodes = errors.New("config file can only be used when deploying a single darknode")
+
+// Deployment are the parameters for deploying a new darknode.
+type Deployment struct {
+	Name     string
+	Tags     string
+	Config   string
+	Network  darknode.Network
+	Region   string
+	Instance string
+	Version  string
+	Image    Image
+	IPv6     bool
+	StaticIP bool
+	Spot     bool
+
+	// SSHCIDRs are the CIDR ranges allowed to reach SSH on the darknode, SSH
+	// is open to anywhere if it's empty.
+	SSHCIDRs []string
+
+	// Quiet only writes the terraform output to the log of the darknode, so
+	// darknodes can be deployed in parallel.
+	Quiet bool
+}
+
+// placementFlag are the flags for the region and instance type of a provider.
+type placementFlag struct {
+	region   string
+	instance string
+}
+
+var placementFlags = map[string]placementFlag{
+	NameAws:     {"aws-region", "aws-instance"},
+	NameDo:      {"do-region", "do-droplet"},
+	NameGcp:     {"gcp-zone", "gcp-machine"},
+	NameHetzner: {"hetzner-location", "hetzner-server-type"},
+}
+
+// ParseDeployments validates the parameters in the cli context and returns the
+// deployments of the darknodes with the given names. The darknodes are spread
+// across the comma separated regions given in the region flag of the provider,
+// or random regions if none are given.
+func ParseDeployments(ctx *cli.Context, p Provider, names []string) ([]Deployment, error) {
+	config := ctx.String("config")
+	if config != "" && len(names) > 1 {
+		return nil, ErrConfigForManyNodes
+	}
+	tags := ctx.String("tags")
+	if _, err := util.ParseTags(tags); err != nil {
+		return nil, err
+	}
+	network, err := darknode.NewNetwork(ctx.String("network"))
+	if err != nil {
+		return nil, err
+	}
+	spot, err := parseSpot(ctx, network)
+	if err != nil {
+		return nil, err
+	}
+	image, err := parseImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFeatures(p, spot, ctx.Bool("static-ip"), false); err != nil {
+		return nil, err
+	}
+
+	var regions []string
+	var instance string
+	if flag, ok := placementFlags[p.Name()]; ok {
+		for _, region := range strings.Split(ctx.String(flag.region), ",") {
+			if region = strings.ToLower(strings.TrimSpace(region)); region != "" {
+				regions = append(regions, region)
+			}
+		}
+		instance = strings.ToLower(strings.TrimSpace(ctx.String(flag.instance)))
+	}
+	regions, err = p.Placements(regions, instance, len(names))
+	if err != nil {
+		return nil, err
+	}
+	latestVersion, err := util.LatestStableRelease()
+	if err != nil {
+		return nil, err
+	}
+
+	deployments := make([]Deployment, len(names))
+	for i, name := range names {
+		deployments[i] = Deployment{
+			Name:     name,
+			Tags:     tags,
+			Config:   config,
+			Network:  network,
+			Region:   regions[i%len(regions)],
+			Instance: instance,
+			Version:  latestVersion,
+			Image:    image,
+			IPv6:     ctx.Bool("ipv6"),
+			StaticIP: ctx.Bool("static-ip"),
+			Spot:     spot,
+		}
+	}
+	return deployments, nil
+}
+
+// DeployNode initialises the node directory and deploys the darknode.
+func DeployNode(p Provider, d Deployment) error {
+	if err := initNode(d.Name, p.Name(), d.Tags, d.Network, d.Config); err != nil {
+		return err
+	}
+	if len(d.SSHCIDRs) > 0 {
+		if err := writeSSHAllowList(d.Name, d.SSHCIDRs); err != nil {
+			return err
+		}
+	}
+	return p.Deploy(d)
+}
+
+// ValidateDeployment checks if the provider offers the features asked for by
+// the deployment, and spot instances are not used for mainnet.
+func ValidateDeployment(p Provider, d Deployment) error {
+	if d.Spot && d.Network == darknode.Mainnet {
+		return ErrSpotOnMainnet
+	}
+	return validateFeatures(p, d.Spot, d.StaticIP, len(d.SSHCIDRs) > 0)
+}
+
+// validateFeatures checks if the provider offers spot instances, static IPs and
+// the SSH allow-list when they are asked for.
+func validateFeatures(p Provider, spot, staticIP, sshAllowList bool) error {
+	if spot && !util.StringInSlice(p.Name(), []string{NameAws, NameGcp}) {
+		return ErrSpotNotSupported
+	}
+	if staticIP && !util.StringInSlice(p.Name(), []string{NameAws, NameDo, NameGcp}) {
+		return ErrStaticIPNotSupported
+	}
+	if sshAllowList && p.Name() == NameDocker {
+		return ErrNotSupported
+	}
+	return nil
+}
+
+// selectRegions returns the regions for deploying the given number of
+// darknodes. The given regions need to be available, otherwise as many random
+// regions as darknodes are selected from the available ones.
+func selectRegions(regions, available []string, count int) ([]string, error) {
+	if len(regions) == 0 {
+		if len(available) == 0 {
+			return nil, ErrRegionNotAvailable
+		}
+		if count > len(available) {
+			count = len(available)
+		}
+		if count < 1 {
+			count = 1
+		}
+		for _, i := range rand.Perm(len(available))[:count] {
+			regions = append(regions, available[i])
+		}
+		return regions, nil
+	}
+
+	for _, region := range regions {
+		if !util.StringInSlice(region, available) {
+			fmt.Printf("[%v] is not available, you can only deploy to below regions:\n", region)
+			fmt.Println(strings.Join(available, ", "))
+			return nil, ErrRegionNotAvailable
+		}
+	}
+	return regions, nil
+}
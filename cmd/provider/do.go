@@ -1,24 +1,62 @@
 package provider
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"net/http"
+	"strings"
 
-	"github.com/renproject/darknode-cli/darknode"
+	"github.com/fatih/color"
 	"github.com/renproject/darknode-cli/util"
 	"github.com/urfave/cli"
 )
 
+// Regex for finding the droplet size and region in the terraform config.
+var (
+	RegexDo = `size\s+=\s*"(?P<instance>.+)"`
+
+	// The region is matched in the droplet, the backend block of the remote
+	// state has a region as well.
+	RegexDoRegion = `(?s)resource\s+"digitalocean_droplet"\s+"darknode"\s*\{.*?\bregion\s*=\s*"(?P<region>[^"]+)"`
+)
+
+// doAPI is the url of the Digital Ocean API, which can be replaced in tests.
+var doAPI = "https://api.digitalocean.com"
+
+// doScopes are the scopes of the Digital Ocean token needed for deploying the
+// darknode, and the requests for checking them. Create scopes are checked with
+// empty requests, which are rejected with 422 when the scope is granted and
+// 401 or 403 when it's not, so nothing is created.
+var doScopes = []struct {
+	scope    string
+	method   string
+	path     string
+	required bool
+}{
+	{"account:read", "GET", "/v2/account", true},
+	{"regions:read", "GET", "/v2/regions?per_page=1", true},
+	{"droplet:read", "GET", "/v2/droplets?per_page=1", true},
+	{"droplet:create", "POST", "/v2/droplets", true},
+	{"ssh_key:read", "GET", "/v2/account/keys?per_page=1", true},
+	{"ssh_key:create", "POST", "/v2/account/keys", true},
+	{"firewall:read", "GET", "/v2/firewalls?per_page=1", true},
+	{"firewall:create", "POST", "/v2/firewalls", true},
+	{"reserved_ip:read", "GET", "/v2/reserved_ips?per_page=1", false},
+	{"reserved_ip:create", "POST", "/v2/reserved_ips", false},
+}
+
 type providerDo struct {
 	token string
 }
 
 func NewDo(ctx *cli.Context) (Provider, error) {
 	token := ctx.String("do-token")
+	if token == "" {
+		return nil, errors.New("please provide the Digital Ocean API token with --do-token")
+	}
 
 	return providerDo{
 		token: token,
@@ -29,68 +67,184 @@ func (p providerDo) Name() string {
 	return NameDo
 }
 
-func (p providerDo) Deploy(ctx *cli.Context) error {
-	name := ctx.String("name")
-	tags := ctx.String("tags")
-	config := ctx.String("config")
+// env returns the environment variables for passing the credentials to terraform.
+func (p providerDo) env() []string {
+	return []string{"DIGITALOCEAN_TOKEN=" + p.token}
+}
 
-	latestVersion, err := util.LatestStableRelease()
-	if err != nil {
-		return err
+func (p providerDo) Placements(regions []string, droplet string, count int) ([]string, error) {
+	if err := p.checkPermissions(count); err != nil {
+		return nil, err
 	}
-	region, droplet, err := validateRegionAndDroplet(ctx)
+	doRegions, err := availableRegions(p.token)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Initialization
-	network, err := darknode.NewNetwork(ctx.String("network"))
+	available := make([]string, 0, len(doRegions))
+	droplets := map[string][]string{}
+	for _, region := range doRegions {
+		available = append(available, region.Slug)
+		droplets[region.Slug] = region.Sizes
+	}
+	regions, err = selectRegions(regions, available, count)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := initNode(name, tags, network, config); err != nil {
-		return err
+	for _, region := range regions {
+		if err := validateDroplet(droplet, region, droplets[region]); err != nil {
+			return nil, err
+		}
 	}
+	return regions, nil
+}
 
-	// Generate terraform config and start deploying
-	if err := p.tfConfig(name, region, droplet, latestVersion); err != nil {
+func (p providerDo) Deploy(d Deployment) error {
+	if err := p.tfConfig(d.Name, d.Region, d.Instance, d.Version, d.Image, d.IPv6, d.StaticIP); err != nil {
+		return err
+	}
+	if err := runTerraform(d.Name, p.env(), d.Quiet); err != nil {
 		return err
 	}
-	if err := runTerraform(name); err != nil {
+	if err := deployed(d); err != nil {
 		return err
 	}
-	return outputURL(name)
+	return waitForReady(d.Name)
+}
+
+func (p providerDo) Destroy(name string) error {
+	return destroyTerraform(name, p.env())
 }
 
-func validateRegionAndDroplet(ctx *cli.Context) (string, string, error) {
-	region := ctx.String("do-region")
-	droplet := ctx.String("do-droplet")
+func (p providerDo) Resize(name, droplet string) error {
+	replacement := fmt.Sprintf(`size        = "%v"`, droplet)
+	return resizeTerraform(name, p.env(), RegexDo, replacement)
+}
 
-	regions, err := availableRegions(ctx)
+func (p providerDo) Status(name string) (Status, error) {
+	return statusTerraform(name)
+}
+
+func (p providerDo) AllowSSH(name string, cidrs []string) error {
+	return allowSSHTerraform(name, p.env(), cidrs)
+}
+
+func (p providerDo) Recover(name string) (bool, error) {
+	return recoverTerraform(name, p.env(), "digitalocean_droplet")
+}
+
+func (p providerDo) Instance(name string) (string, string, error) {
+	return instanceTerraform(name, RegexDoRegion, RegexDo)
+}
+
+func (p providerDo) Regions() ([]string, error) {
+	regions, err := availableRegions(p.token)
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+	slugs := make([]string, len(regions))
+	for i := range regions {
+		slugs[i] = regions[i].Slug
 	}
+	return slugs, nil
+}
 
-	// Parse the input region or pick one region randomly
-	if region == "" {
-		if len(regions) == 0 {
-			return "", "", ErrRegionNotAvailable
+// InstanceTypes returns all the droplet sizes available in the given region.
+func (p providerDo) InstanceTypes(region string) ([]InstanceType, error) {
+	sizes, err := availableSizes(p.token)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]InstanceType, 0)
+	for _, size := range sizes {
+		if util.StringInSlice(region, size.Regions) {
+			instances = append(instances, InstanceType{
+				Name:         size.Slug,
+				CPUs:         size.Vcpus,
+				Memory:       float64(size.Memory) / 1024,
+				PriceHourly:  size.PriceHourly,
+				PriceMonthly: size.PriceMonthly,
+				Currency:     "USD",
+			})
 		}
-		randomRegion := regions[rand.Intn(len(regions))]
-		return randomRegion.Slug, droplet, validateDroplet(droplet, randomRegion.Slug, randomRegion.Sizes)
-	} else {
-		var chosenRegion Region
-		for i := range regions {
-			if region == regions[i].Slug {
-				chosenRegion = regions[i]
-				break
-			}
+	}
+	if len(instances) == 0 {
+		return nil, ErrRegionNotAvailable
+	}
+	return instances, nil
+}
+
+// checkPermissions makes sure the token has the scopes needed for managing the
+// resources in the terraform template, and the account is active and can create
+// the given number of droplets.
+func (p providerDo) checkPermissions(count int) error {
+	missing, optional := make([]string, 0), make([]string, 0)
+	for _, scope := range doScopes {
+		var body []byte
+		if scope.method == "POST" {
+			body = []byte("{}")
+		}
+		code, _, err := doRequest(scope.method, doAPI+scope.path, p.token, body)
+		if err != nil {
+			return err
+		}
+		if code == http.StatusUnauthorized && scope.scope == "account:read" {
+			return errors.New("the Digital Ocean token is invalid or has expired")
+		}
+		if code != http.StatusUnauthorized && code != http.StatusForbidden {
+			continue
 		}
-		if chosenRegion.Name == "" {
-			return "", "", ErrRegionNotAvailable
+		if scope.required {
+			missing = append(missing, scope.scope)
+		} else {
+			optional = append(optional, scope.scope)
+		}
+	}
+	if len(optional) > 0 {
+		color.Yellow("The Digital Ocean token is missing the %v scopes, which are needed for deploying with --static-ip", strings.Join(optional, ", "))
+	}
+	if len(missing) > 0 {
+		color.Red("The Digital Ocean token is missing below scopes for deploying the darknode:")
+		for _, scope := range missing {
+			color.Red(scope)
 		}
-		return chosenRegion.Slug, droplet, validateDroplet(droplet, chosenRegion.Slug, chosenRegion.Sizes)
+		return ErrInsufficientPermission
 	}
+
+	data, err := doGet(doAPI+"/v2/account", p.token)
+	if err != nil {
+		return err
+	}
+	account := struct {
+		Account struct {
+			DropletLimit  int    `json:"droplet_limit"`
+			Status        string `json:"status"`
+			StatusMessage string `json:"status_message"`
+		} `json:"account"`
+	}{}
+	if err := json.Unmarshal(data, &account); err != nil {
+		return err
+	}
+	if account.Account.Status != "active" {
+		return fmt.Errorf("digital ocean account is %v: %v", account.Account.Status, account.Account.StatusMessage)
+	}
+
+	// Check if the account has reached the droplet limit.
+	data, err = doGet(doAPI+"/v2/droplets?per_page=1", p.token)
+	if err != nil {
+		return err
+	}
+	droplets := struct {
+		Meta struct {
+			Total int `json:"total"`
+		} `json:"meta"`
+	}{}
+	if err := json.Unmarshal(data, &droplets); err != nil {
+		return err
+	}
+	if droplets.Meta.Total+count > account.Account.DropletLimit {
+		return fmt.Errorf("digital ocean account has %v droplets and cannot create %v more, the droplet limit is %v", droplets.Meta.Total, count, account.Account.DropletLimit)
+	}
+	return nil
 }
 
 // validateDroplet validates whether the droplet is available in the region.
@@ -116,32 +270,49 @@ type Region struct {
 	Available bool     `json:"available"`
 }
 
-// availableRegions sends a GET request to Digital Ocean API to get all available regions and droplet sizes of the given
-// Digital Ocean token.
-func availableRegions(ctx *cli.Context) ([]Region, error) {
-	token := ctx.String("do-token")
+// Size is the json object of a droplet size returned by the digital-ocean API
+type Size struct {
+	Slug         string   `json:"slug"`
+	Memory       int      `json:"memory"`
+	Vcpus        int      `json:"vcpus"`
+	Disk         int      `json:"disk"`
+	PriceMonthly float64  `json:"price_monthly"`
+	PriceHourly  float64  `json:"price_hourly"`
+	Regions      []string `json:"regions"`
+	Available    bool     `json:"available"`
+}
 
-	url := "https://api.digitalocean.com/v2/regions"
-	req, err := http.NewRequest("GET", url, nil)
+// availableSizes sends a GET request to Digital Ocean API to get all available droplet sizes, including the hardware
+// and price of each size.
+func availableSizes(token string) ([]Size, error) {
+	data, err := doGet(doAPI+"/v2/sizes?per_page=200", token)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
+
+	// Unmarshal the response
+	sizes := struct {
+		Sizes []Size `json:"sizes"`
+	}{}
+	if err := json.Unmarshal(data, &sizes); err != nil {
 		return nil, err
 	}
+	availableSizes := make([]Size, 0)
+	for _, i := range sizes.Sizes {
+		if i.Available {
+			availableSizes = append(availableSizes, i)
+		}
+	}
+	return availableSizes, nil
+}
 
-	// Check the response status code
-	data, err := ioutil.ReadAll(resp.Body)
+// availableRegions sends a GET request to Digital Ocean API to get all available regions and droplet sizes of the given
+// Digital Ocean token.
+func availableRegions(token string) ([]Region, error) {
+	data, err := doGet(doAPI+"/v2/regions", token)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(string(data))
-	}
 
 	// Unmarshal the response
 	regions := struct {
@@ -159,3 +330,38 @@ func availableRegions(ctx *cli.Context) ([]Region, error) {
 	}
 	return availableRegions, nil
 }
+
+// doGet sends an authorized GET request to the Digital Ocean API and returns the
+// response body.
+func doGet(url, token string) ([]byte, error) {
+	code, data, err := doRequest("GET", url, token, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the response status code
+	if code != http.StatusOK {
+		return nil, errors.New(string(data))
+	}
+	return data, nil
+}
+
+// doRequest sends an authorized request to the Digital Ocean API and returns the
+// status code and body of the response.
+func doRequest(method, url, token string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
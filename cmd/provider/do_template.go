@@ -2,53 +2,53 @@ package provider
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"text/template"
 
 	"github.com/renproject/darknode-cli/util"
 )
 
 type doTerraform struct {
-	Name          string
-	Token         string
-	Region        string
-	Size          string
-	ConfigPath    string
-	PubKeyPath    string
-	PriKeyPath    string
-	ServiceFile   string
-	LatestVersion string
+	Name         string
+	Region       string
+	Size         string
+	PubKeyPath   string
+	UserDataPath string
+	Image        string
+	ImageName    string
+	IPv6         bool
+	StaticIP     bool
+	Firewall     string
+	Config       string
 }
 
-func (p providerDo) tfConfig(name, region, droplet, latestVersion string) error {
-	tf := doTerraform{
-		Name:          name,
-		Token:         p.token,
-		Region:        region,
-		Size:          droplet,
-		ConfigPath:    fmt.Sprintf("~/.darknode/darknodes/%v/config.json", name),
-		PubKeyPath:    fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair.pub", name),
-		PriKeyPath:    fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name),
-		ServiceFile:   darknodeService,
-		LatestVersion: latestVersion,
+func (p providerDo) tfConfig(name, region, droplet, latestVersion string, image Image, ipv6, staticIP bool) error {
+	if err := writeUserData(name, latestVersion); err != nil {
+		return err
 	}
 
-	t, err := template.New("do").Parse(doTemplate)
-	if err != nil {
-		return err
+	tf := doTerraform{
+		Name:         name,
+		Region:       region,
+		Size:         droplet,
+		PubKeyPath:   fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair.pub", name),
+		UserDataPath: fmt.Sprintf("~/.darknode/darknodes/%v/user-data.yaml", name),
+		Image:        image.Name,
+		ImageName:    image.Do,
+		IPv6:         ipv6,
+		StaticIP:     staticIP,
+		Firewall:     sshVariable + ufwResource("digitalocean_droplet.darknode.id", "digitalocean_droplet.darknode.ipv4_address", `"root"`, fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name)),
+		Config:       configResource("digitalocean_droplet.darknode.id", "digitalocean_droplet.darknode.ipv4_address", name),
 	}
-	tfFile, err := os.Create(filepath.Join(util.NodePath(name), "main.tf"))
+
+	t, err := template.New(NameDo).Parse(doTemplate)
 	if err != nil {
 		return err
 	}
-	return t.Execute(tfFile, tf)
+	return writeTerraformConfig(name, util.NodePath(name), t, tf)
 }
 
 var doTemplate = `
-provider "digitalocean" {
-  token = "{{.Token}}"
-}
+provider "digitalocean" {}
 
 resource "digitalocean_ssh_key" "darknode" {
    name       = "{{.Name}}"
@@ -57,86 +57,81 @@ resource "digitalocean_ssh_key" "darknode" {
 
 resource "digitalocean_droplet" "darknode" {
   provider    = digitalocean
-  image       = "ubuntu-18-04-x64"
+  image       = "{{.ImageName}}"
   name        = "{{.Name}}"
   region      = "{{.Region}}"
   size        = "{{.Size}}"
   monitoring  = true
   resize_disk = false
+  ipv6        = {{.IPv6}}
+  user_data   = file("{{.UserDataPath}}")
 
   ssh_keys = [
     digitalocean_ssh_key.darknode.id
   ]
+}
+
+{{if .StaticIP}}
+resource "digitalocean_reserved_ip" "darknode" {
+  region     = "{{.Region}}"
+  droplet_id = digitalocean_droplet.darknode.id
+}
+{{end}}
+resource "digitalocean_firewall" "darknode" {
+  name        = "darknode-{{.Name}}"
+  droplet_ids = [digitalocean_droplet.darknode.id]
+
+  inbound_rule {
+    protocol         = "tcp"
+    port_range       = "22"
+    source_addresses = var.ssh_cidrs
+  }
 
-  provisioner "remote-exec" {
-	
-	inline = [
-      "set -x",
-      "until sudo apt update; do sleep 4; done",
-      "until sudo apt-get -y update; do sleep 4; done",
-      "sudo adduser darknode --gecos \",,,\" --disabled-password",
-      "sudo rsync --archive --chown=darknode:darknode ~/.ssh /home/darknode",
-	  "curl -sSL https://repos.insights.digitalocean.com/install.sh | sudo bash",
-      "until sudo apt-get install ufw; do sleep 4; done",
-      "sudo ufw limit 22/tcp",
-      "sudo ufw allow 18514/tcp", 
-      "sudo ufw allow 18515/tcp", 
-      "sudo ufw --force enable",
-	]
-
-    connection {
-      host        = self.ipv4_address
-      type        = "ssh"
-      user        = "root"
-      private_key = file("{{.PriKeyPath}}")
-    }
+  inbound_rule {
+    protocol         = "tcp"
+    port_range       = "18514-18515"
+    source_addresses = ["0.0.0.0/0", "::/0"]
   }
 
-  provisioner "file" {
+  inbound_rule {
+    protocol         = "icmp"
+    source_addresses = ["0.0.0.0/0", "::/0"]
+  }
 
-    source      = "{{.ConfigPath}}"
-    destination = "$HOME/config.json"
+  outbound_rule {
+    protocol              = "tcp"
+    port_range            = "1-65535"
+    destination_addresses = ["0.0.0.0/0", "::/0"]
+  }
 
-    connection {
-      host        = self.ipv4_address
-      type        = "ssh"
-      user        = "darknode"
-      private_key = file("{{.PriKeyPath}}")
-    }
+  outbound_rule {
+    protocol              = "udp"
+    port_range            = "1-65535"
+    destination_addresses = ["0.0.0.0/0", "::/0"]
   }
 
-  provisioner "remote-exec" {
-	
-	inline = [
-      "set -x",
-	  "mkdir -p $HOME/.darknode/bin",
-      "mkdir -p $HOME/.config/systemd/user",
-      "mv $HOME/config.json $HOME/.darknode/config.json",
-	  "curl -sL https://www.github.com/renproject/darknode-release/releases/download/{{.LatestVersion}}/darknode > ~/.darknode/bin/darknode",
-	  "chmod +x ~/.darknode/bin/darknode",
-      "echo {{.LatestVersion}} > ~/.darknode/version",
-	  <<EOT
-	  echo "{{.ServiceFile}}" > ~/.config/systemd/user/darknode.service
-      EOT
-      ,
-	  "loginctl enable-linger darknode",
-      "systemctl --user enable darknode.service",
-      "systemctl --user start darknode.service",
-	]
-
-    connection {
-      host        = self.ipv4_address
-      type        = "ssh"
-      user        = "darknode"
-      private_key = file("{{.PriKeyPath}}")
-    }
+  outbound_rule {
+    protocol              = "icmp"
+    destination_addresses = ["0.0.0.0/0", "::/0"]
   }
 }
-
+{{.Firewall}}{{.Config}}
 output "provider" {
   value = "do"
 }
 
+output "image" {
+  value = "{{.Image}}"
+}
+
 output "ip" {
+{{- if .StaticIP}}
+  value = digitalocean_reserved_ip.darknode.ip_address
+{{- else}}
   value = digitalocean_droplet.darknode.ipv4_address
+{{- end}}
+}
+
+output "ipv6" {
+  value = digitalocean_droplet.darknode.ipv6_address
 }`
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeDoAPI serves the Digital Ocean API for a token with all scopes except the
+// given ones.
+func fakeDoAPI(t *testing.T, denied ...string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		for _, scope := range doScopes {
+			path := strings.SplitN(scope.path, "?", 2)[0]
+			if r.Method != scope.method || r.URL.Path != path {
+				continue
+			}
+			for _, deniedScope := range denied {
+				if deniedScope == scope.scope {
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"id": "forbidden", "message": "You are not authorized to perform this operation"}`))
+					return
+				}
+			}
+		}
+		switch {
+		case r.Method == "POST":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case r.URL.Path == "/v2/account":
+			w.Write([]byte(`{"account": {"droplet_limit": 10, "status": "active"}}`))
+		case r.URL.Path == "/v2/droplets":
+			w.Write([]byte(`{"droplets": [], "meta": {"total": 8}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	oldAPI := doAPI
+	doAPI = server.URL
+	t.Cleanup(func() {
+		doAPI = oldAPI
+		server.Close()
+	})
+}
+
+func TestDoCheckPermissions(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		denied []string
+		count  int
+		err    string
+	}{
+		{"all scopes", "token", nil, 2, ""},
+		{"read only", "token", []string{"droplet:create", "ssh_key:create", "firewall:create"}, 1, ErrInsufficientPermission.Error()},
+		{"no account scope", "token", []string{"account:read", "droplet:read"}, 1, ErrInsufficientPermission.Error()},
+		{"no reserved ip", "token", []string{"reserved_ip:create"}, 1, ""},
+		{"invalid token", "wrong", nil, 1, "invalid"},
+		{"droplet limit", "token", nil, 3, "droplet limit is 10"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeDoAPI(t, test.denied...)
+			err := providerDo{token: test.token}.checkPermissions(test.count)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("expected %q, got %v", test.err, err)
+			}
+			if test.err == ErrInsufficientPermission.Error() && !errors.Is(err, ErrInsufficientPermission) {
+				t.Fatalf("expected %v, got %v", ErrInsufficientPermission, err)
+			}
+		})
+	}
+}
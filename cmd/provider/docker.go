@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"errors"
+
+	"github.com/renproject/darknode-cli/util"
+	"github.com/urfave/cli"
+)
+
+// ErrDockerNotAvailable is returned when we cannot connect to the docker daemon.
+var ErrDockerNotAvailable = errors.New("cannot connect to the docker daemon, please make sure docker is installed and running")
+
+// providerDocker deploys darknodes to docker containers on the local machine.
+// It is meant to be used for development and testing.
+type providerDocker struct {
+}
+
+func NewDocker(ctx *cli.Context) (Provider, error) {
+	if err := util.SilentRun("docker", "info"); err != nil {
+		return nil, ErrDockerNotAvailable
+	}
+	return providerDocker{}, nil
+}
+
+func (p providerDocker) Name() string {
+	return NameDocker
+}
+
+// env returns no environment variables as no cloud credentials are needed.
+func (p providerDocker) env() []string {
+	return nil
+}
+
+// Placements returns a single empty region, as all darknodes are deployed to
+// the local machine.
+func (p providerDocker) Placements(regions []string, instance string, count int) ([]string, error) {
+	return []string{""}, nil
+}
+
+func (p providerDocker) Deploy(d Deployment) error {
+	if err := p.tfConfig(d.Name, d.Version, d.Image); err != nil {
+		return err
+	}
+	if err := runTerraform(d.Name, p.env(), d.Quiet); err != nil {
+		return err
+	}
+	if err := deployed(d); err != nil {
+		return err
+	}
+	return waitForReady(d.Name)
+}
+
+func (p providerDocker) Destroy(name string) error {
+	return destroyTerraform(name, p.env())
+}
+
+func (p providerDocker) Resize(name, instance string) error {
+	return ErrNotSupported
+}
+
+func (p providerDocker) Status(name string) (Status, error) {
+	return statusTerraform(name)
+}
+
+func (p providerDocker) AllowSSH(name string, cidrs []string) error {
+	return ErrNotSupported
+}
+
+func (p providerDocker) Recover(name string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (p providerDocker) Instance(name string) (string, string, error) {
+	return "", "", ErrNotSupported
+}
+
+func (p providerDocker) Regions() ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (p providerDocker) InstanceTypes(region string) ([]InstanceType, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,252 @@
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+type dockerTerraform struct {
+	Name          string
+	Path          string
+	ConfigPath    string
+	ServicePath   string
+	BootstrapPath string
+	PriKeyPath    string
+	Image         string
+	ImageName     string
+}
+
+// tfConfig generates the docker image files and the terraform config file for
+// deploying to a local docker container.
+func (p providerDocker) tfConfig(name, latestVersion string, image Image) error {
+	if err := writeBootstrapFiles(name, latestVersion); err != nil {
+		return err
+	}
+
+	path := util.NodePath(name)
+	files := map[string]string{
+		"Dockerfile":    dockerfile,
+		".dockerignore": dockerignore,
+		"entrypoint.sh": dockerEntrypoint,
+		"systemctl":     dockerSystemctl,
+	}
+	for file, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(content), 0700); err != nil {
+			return err
+		}
+	}
+
+	tf := dockerTerraform{
+		Name:          name,
+		Path:          path,
+		ConfigPath:    fmt.Sprintf("~/.darknode/darknodes/%v/config.json", name),
+		ServicePath:   fmt.Sprintf("~/.darknode/darknodes/%v/darknode.service", name),
+		BootstrapPath: fmt.Sprintf("~/.darknode/darknodes/%v/bootstrap.sh", name),
+		PriKeyPath:    fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name),
+		Image:         image.Name,
+		ImageName:     image.Docker,
+	}
+
+	t, err := template.New(NameDocker).Parse(dockerTemplate)
+	if err != nil {
+		return err
+	}
+	return writeTerraformConfig(name, path, t, tf)
+}
+
+// dockerfile builds an image which has the same user and ssh setup as the cloud
+// instances, so that all commands can talk to the container over ssh.
+var dockerfile = `ARG IMAGE=ubuntu:24.04
+FROM $IMAGE
+
+RUN apt-get update && \
+    DEBIAN_FRONTEND=noninteractive apt-get install -y ca-certificates curl openssh-server procps && \
+    mkdir -p /run/sshd && \
+    rm -rf /var/lib/apt/lists/*
+
+RUN adduser darknode --gecos ",,," --disabled-password
+COPY ssh_keypair.pub /home/darknode/.ssh/authorized_keys
+RUN chown -R darknode:darknode /home/darknode/.ssh && \
+    chmod 700 /home/darknode/.ssh && \
+    chmod 600 /home/darknode/.ssh/authorized_keys
+
+COPY systemctl /usr/local/bin/systemctl
+COPY entrypoint.sh /usr/local/bin/entrypoint.sh
+
+EXPOSE 22 18514 18515
+CMD ["/usr/local/bin/entrypoint.sh"]
+`
+
+// dockerignore makes sure the private keys never end up in the build context.
+var dockerignore = `*
+!ssh_keypair.pub
+!systemctl
+!entrypoint.sh
+`
+
+// dockerEntrypoint starts the darknode if the service has been enabled and
+// runs sshd in the foreground.
+var dockerEntrypoint = `#!/bin/bash
+if [ -f /home/darknode/.darknode/enabled ]; then
+  su darknode -c "/usr/local/bin/systemctl --user start darknode"
+fi
+exec /usr/sbin/sshd -D
+`
+
+// dockerSystemctl is a minimal replacement of systemctl for containers without
+// systemd. It supports the `--user` commands we use for managing the darknode
+// service and restarts the darknode on failure.
+var dockerSystemctl = `#!/bin/bash
+action=""
+for arg in "$@"; do
+  case "$arg" in
+    --user) ;;
+    start|stop|restart|enable|disable|status|daemon-reload) action="$arg" ;;
+  esac
+done
+
+home="$HOME/.darknode"
+pidfile="$home/darknode.pid"
+logfile="$home/darknode.log"
+
+running() {
+  [ -f "$pidfile" ] && kill -0 "$(cat "$pidfile")" 2>/dev/null
+}
+
+start() {
+  running && return 0
+  cd "$home"
+  setsid bash -c "while true; do $home/bin/darknode --config $home/config.json; [ \$? -eq 0 ] && break; sleep 5; done" >> "$logfile" 2>&1 < /dev/null &
+  echo $! > "$pidfile"
+}
+
+stop() {
+  if running; then
+    kill -HUP -- "-$(cat "$pidfile")" 2>/dev/null
+  fi
+  rm -f "$pidfile"
+}
+
+case "$action" in
+  start) start ;;
+  stop) stop ;;
+  restart) stop; sleep 1; start ;;
+  enable) touch "$home/enabled" ;;
+  disable) rm -f "$home/enabled" ;;
+  status) running && echo "active (running)" || { echo "inactive (dead)"; exit 3; } ;;
+  daemon-reload) ;;
+  *) echo "unsupported command: $*" >&2; exit 1 ;;
+esac
+`
+
+var dockerTemplate = `
+provider "docker" {}
+
+resource "null_resource" "image" {
+
+  triggers = {
+    dockerfile = filemd5("{{.Path}}/Dockerfile")
+    systemctl  = filemd5("{{.Path}}/systemctl")
+  }
+
+  provisioner "local-exec" {
+    working_dir = "{{.Path}}"
+    command     = "docker build --build-arg IMAGE={{.ImageName}} -t darknode-{{.Name}} ."
+  }
+}
+
+resource "docker_image" "darknode" {
+  name         = "darknode-{{.Name}}"
+  keep_locally = false
+  depends_on   = [null_resource.image]
+}
+
+resource "docker_container" "darknode" {
+  name    = "{{.Name}}"
+  image   = docker_image.darknode.image_id
+  restart = "unless-stopped"
+
+  // The bridge network of the container cannot be reached from the host on
+  // Docker Desktop, so the ports are published on the loopback interface.
+  ports {
+    internal = 22
+    ip       = "127.0.0.1"
+  }
+
+  ports {
+    internal = 18514
+    ip       = "127.0.0.1"
+  }
+
+  ports {
+    internal = 18515
+    ip       = "127.0.0.1"
+  }
+
+  provisioner "file" {
+
+    source      = "{{.ConfigPath}}"
+    destination = "/home/darknode/config.json"
+
+    connection {
+      host        = "127.0.0.1"
+      port        = [for port in self.ports : port.external if port.internal == 22][0]
+      type        = "ssh"
+      user        = "darknode"
+      private_key = file("{{.PriKeyPath}}")
+    }
+  }
+
+  provisioner "file" {
+
+    source      = "{{.ServicePath}}"
+    destination = "/home/darknode/darknode.service"
+
+    connection {
+      host        = "127.0.0.1"
+      port        = [for port in self.ports : port.external if port.internal == 22][0]
+      type        = "ssh"
+      user        = "darknode"
+      private_key = file("{{.PriKeyPath}}")
+    }
+  }
+
+  provisioner "file" {
+
+    source      = "{{.BootstrapPath}}"
+    destination = "/home/darknode/bootstrap.sh"
+
+    connection {
+      host        = "127.0.0.1"
+      port        = [for port in self.ports : port.external if port.internal == 22][0]
+      type        = "ssh"
+      user        = "darknode"
+      private_key = file("{{.PriKeyPath}}")
+    }
+  }
+
+  // Run the same bootstrap script which cloud-init runs on cloud providers.
+  provisioner "local-exec" {
+    command = "docker exec {{.Name}} bash /home/darknode/bootstrap.sh /home/darknode container"
+  }
+}
+
+output "provider" {
+  value = "docker"
+}
+
+output "image" {
+  value = "{{.Image}}"
+}
+
+output "ip" {
+  value = "127.0.0.1"
+}
+
+output "ssh_port" {
+  value = [for port in docker_container.darknode.ports : port.external if port.internal == 22][0]
+}`
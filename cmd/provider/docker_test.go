@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+func TestDockerTemplate(t *testing.T) {
+	setupNode(t, "test", "")
+	stubRelease(t)
+	image, err := ParseImage("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (providerDocker{}).tfConfig("test", "0.4.0", image); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(util.NodePath("test"), "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf := string(data)
+
+	for _, expected := range []string{
+		`source  = "kreuzwerker/docker"`,
+		`name    = "test"`,
+		`docker build --build-arg IMAGE=` + image.Docker + ` -t darknode-test .`,
+		`host        = "127.0.0.1"`,
+		`port        = [for port in self.ports : port.external if port.internal == 22][0]`,
+		`value = "127.0.0.1"`,
+		`value = [for port in docker_container.darknode.ports : port.external if port.internal == 22][0]`,
+		`value = "docker"`,
+		`value = "` + image.Name + `"`,
+		`docker exec test bash /home/darknode/bootstrap.sh /home/darknode container`,
+	} {
+		if !strings.Contains(tf, expected) {
+			t.Errorf("main.tf does not contain %q", expected)
+		}
+	}
+	// The IP of the container on the bridge network cannot be reached from the
+	// host on Docker Desktop.
+	if regexp.MustCompile(`ip_address`).MatchString(tf) {
+		t.Error("main.tf uses the IP address of the container")
+	}
+	for _, port := range []string{"22", "18514", "18515"} {
+		if !regexp.MustCompile(`ports \{\s+internal = ` + port + `\s+ip       = "127.0.0.1"\s+\}`).MatchString(tf) {
+			t.Errorf("port %v is not published on the loopback interface", port)
+		}
+	}
+	if !regexProviderOutput.MatchString(tf) {
+		t.Error("main.tf does not have the provider output")
+	}
+	for _, file := range []string{"Dockerfile", ".dockerignore", "entrypoint.sh", "systemctl", "bootstrap.sh", "darknode.service"} {
+		if _, err := ioutil.ReadFile(filepath.Join(util.NodePath("test"), file)); err != nil {
+			t.Errorf("cannot read %v, err = %v", file, err)
+		}
+	}
+
+	// Validate the config with terraform if it's installed and the providers
+	// can be downloaded.
+	terraform, err := exec.LookPath("terraform")
+	if err != nil {
+		t.Skip("terraform is not installed, skip validating main.tf")
+	}
+	init := exec.Command(terraform, "init", "-backend=false", "-input=false", "-no-color")
+	init.Dir = util.NodePath("test")
+	if output, err := init.CombinedOutput(); err != nil {
+		t.Skipf("cannot initialise terraform, skip validating main.tf: %s", output)
+	}
+	validate := exec.Command(terraform, "validate", "-no-color")
+	validate.Dir = util.NodePath("test")
+	if output, err := validate.CombinedOutput(); err != nil {
+		t.Fatalf("invalid main.tf: %s", output)
+	}
+}
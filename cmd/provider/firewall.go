@@ -0,0 +1,318 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/util"
+)
+
+var (
+	// ErrFirewallNotManaged is returned when the darknode was deployed by an
+	// older version of the CLI whose terraform config has no SSH allow-list.
+	ErrFirewallNotManaged = errors.New("firewall of the darknode is not managed by the CLI, please run `darknode migrate` first")
+
+	// ErrEmptyAllowList is returned when no CIDR range is given for the SSH
+	// allow-list.
+	ErrEmptyAllowList = errors.New("at least one CIDR range needs to be allowed to reach SSH")
+)
+
+// DefaultSSHAllowList allows SSH from anywhere, which is what darknodes
+// deployed without an allow-list use.
+var DefaultSSHAllowList = []string{"0.0.0.0/0", "::/0"}
+
+// sshVariable declares the terraform variable of the CIDR ranges which are
+// allowed to reach SSH on the darknode. The value is set in the
+// `terraform.tfvars.json` file of the node directory.
+var sshVariable = `
+variable "ssh_cidrs" {
+  description = "CIDR ranges allowed to reach SSH on the darknode"
+  type        = list(string)
+  default     = ["0.0.0.0/0", "::/0"]
+}
+
+locals {
+  ssh_cidrs_ipv4 = [for cidr in var.ssh_cidrs : cidr if length(regexall(":", cidr)) == 0]
+  ssh_cidrs_ipv6 = [for cidr in var.ssh_cidrs : cidr if length(regexall(":", cidr)) > 0]
+}
+`
+
+// ufwResource returns a terraform resource which updates the SSH rules of ufw
+// on the host whenever the allow-list or the server changes. It waits for
+// cloud-init to finish so the rules are not overwritten by the bootstrap script,
+// and does nothing if ufw is not active, e.g. on existing servers.
+// New rules are added before stale ones are removed, so the connection is never
+// locked out half way. The applied ranges are kept in /etc/darknode-ssh-cidrs.
+func ufwResource(server, host, user, keyPath string) string {
+	return fmt.Sprintf(`
+resource "null_resource" "ufw" {
+
+  triggers = {
+    server    = %v
+    ssh_cidrs = join(" ", var.ssh_cidrs)
+  }
+
+  provisioner "remote-exec" {
+
+    inline = [
+      <<EOT
+      if command -v cloud-init > /dev/null; then sudo cloud-init status --wait > /dev/null || true; fi
+      if ! command -v ufw > /dev/null || ! sudo ufw status | grep -q "Status: active"; then exit 0; fi
+      new="${self.triggers.ssh_cidrs}"
+      for cidr in $new; do sudo ufw limit from $cidr to any port 22 proto tcp; done
+      sudo ufw --force delete limit 22/tcp || true
+      if [ -f /etc/darknode-ssh-cidrs ]; then
+        for old in $(cat /etc/darknode-ssh-cidrs); do
+          case " $new " in
+            *" $old "*) ;;
+            *) sudo ufw --force delete limit from $old to any port 22 proto tcp || true ;;
+          esac
+        done
+      fi
+      echo "$new" | sudo tee /etc/darknode-ssh-cidrs > /dev/null
+      EOT
+    ]
+
+    connection {
+      host        = %v
+      type        = "ssh"
+      user        = %v
+      private_key = file("%v")
+    }
+  }
+}
+`, server, host, user, keyPath)
+}
+
+// legacyFirewall describes how the SSH allow-list is added to the terraform
+// config of a darknode deployed by an older version of the CLI.
+type legacyFirewall struct {
+	// sshRule matches the rule of the cloud firewall which allows SSH from
+	// anywhere, it's replaced with replacement. Nil if there's no cloud
+	// firewall.
+	sshRule     *regexp.Regexp
+	replacement string
+
+	// resources are added to the config, formatted with the darknode name.
+	resources string
+
+	// Arguments of the ufw resource.
+	server, host, user string
+}
+
+var legacyFirewalls = map[string]legacyFirewall{
+	NameAws: {
+		sshRule: regexp.MustCompile(`(?m)^  // SSH\n  ingress \{\s*from_port\s*=\s*22\s*to_port\s*=\s*22\s*protocol\s*=\s*"tcp"\s*cidr_blocks\s*=\s*\["0\.0\.0\.0/0"\]\s*\}\n`),
+		replacement: `  // SSH
+  dynamic "ingress" {
+    for_each = length(local.ssh_cidrs_ipv4) > 0 ? [local.ssh_cidrs_ipv4] : []
+    content {
+      from_port   = 22
+      to_port     = 22
+      protocol    = "tcp"
+      cidr_blocks = ingress.value
+    }
+  }
+
+  dynamic "ingress" {
+    for_each = length(local.ssh_cidrs_ipv6) > 0 ? [local.ssh_cidrs_ipv6] : []
+    content {
+      from_port        = 22
+      to_port          = 22
+      protocol         = "tcp"
+      ipv6_cidr_blocks = ingress.value
+    }
+  }
+`,
+		server: "aws_instance.darknode.id",
+		host:   "aws_instance.darknode.public_ip",
+		user:   `"ubuntu"`,
+	},
+
+	// Digital Ocean darknodes had no cloud firewall, only ufw.
+	NameDo: {
+		server: "digitalocean_droplet.darknode.id",
+		host:   "digitalocean_droplet.darknode.ipv4_address",
+		user:   `"root"`,
+	},
+
+	// Google Cloud darknodes had no IPv6, so SSH is only allowed from the IPv4
+	// ranges.
+	NameGcp: {
+		sshRule:     regexp.MustCompile(`ports\s*=\s*\["22",\s*"18514",\s*"18515"\]`),
+		replacement: `ports = ["18514", "18515"]`,
+		resources: `
+resource "google_compute_firewall" "darknode_ssh" {
+  count         = length(local.ssh_cidrs_ipv4) > 0 ? 1 : 0
+  network       = google_compute_network.darknode_network.name
+  name          = "%v-ssh"
+  source_ranges = local.ssh_cidrs_ipv4
+
+  allow {
+    protocol = "tcp"
+    ports    = ["22"]
+  }
+
+  target_tags = ["darknode"]
+}
+`,
+		server: "google_compute_instance.darknode.id",
+		host:   "google_compute_instance.darknode.network_interface[0].access_config[0].nat_ip",
+		user:   `"ubuntu"`,
+	},
+}
+
+// MigrateFirewall adds the SSH allow-list to the terraform config of a darknode
+// deployed by an older version of the CLI, so it can be restricted with
+// `darknode firewall allow-ssh`. The allow-list starts open to anywhere like
+// before and is applied on the next terraform apply. It returns whether the
+// config has been changed.
+func MigrateFirewall(name string) (bool, error) {
+	if isFirewallManaged(name) {
+		return false, nil
+	}
+	path := filepath.Join(util.NodePath(name), "main.tf")
+	tf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	match := regexProviderOutput.FindSubmatch(tf)
+	if len(match) < 2 {
+		return false, fmt.Errorf("cannot find the provider of [%v] in main.tf", name)
+	}
+	legacy, ok := legacyFirewalls[string(match[1])]
+	if !ok {
+		return false, fmt.Errorf("cannot migrate the firewall of [%v] deployed on %s", name, match[1])
+	}
+	if legacy.sshRule != nil {
+		if !legacy.sshRule.Match(tf) {
+			return false, fmt.Errorf("cannot find the SSH rule of [%v] in main.tf, it may have been changed manually", name)
+		}
+		tf = legacy.sshRule.ReplaceAllLiteral(tf, []byte(legacy.replacement))
+	}
+	if legacy.resources != "" {
+		tf = append(tf, fmt.Sprintf(legacy.resources, name)...)
+	}
+	keyPath := fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name)
+	tf = append(tf, sshVariable+ufwResource(legacy.server, legacy.host, legacy.user, keyPath)...)
+	return true, ioutil.WriteFile(path, tf, 0600)
+}
+
+// sshAllowListFile is the terraform variables file which contains the SSH
+// allow-list of the darknode.
+func sshAllowListFile(name string) string {
+	return filepath.Join(util.NodePath(name), "terraform.tfvars.json")
+}
+
+// writeSSHAllowList writes the CIDR ranges which are allowed to reach SSH on the
+// darknode to the terraform variables file, they are applied on the next
+// terraform apply.
+func writeSSHAllowList(name string, cidrs []string) error {
+	data, err := json.MarshalIndent(map[string][]string{"ssh_cidrs": cidrs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(sshAllowListFile(name), data, 0600)
+}
+
+// isFirewallManaged returns whether the terraform config of the darknode has
+// the SSH allow-list.
+func isFirewallManaged(name string) bool {
+	tf, err := ioutil.ReadFile(filepath.Join(util.NodePath(name), "main.tf"))
+	return err == nil && strings.Contains(string(tf), `variable "ssh_cidrs"`)
+}
+
+// SSHAllowList returns the CIDR ranges which are allowed to reach SSH on the
+// darknode. It returns ErrFirewallNotManaged if the darknode was deployed by an
+// older version of the CLI, in which case SSH is open to anywhere.
+func SSHAllowList(name string) ([]string, error) {
+	if !isFirewallManaged(name) {
+		return DefaultSSHAllowList, ErrFirewallNotManaged
+	}
+	data, err := ioutil.ReadFile(sshAllowListFile(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultSSHAllowList, nil
+		}
+		return nil, err
+	}
+	vars := struct {
+		SSHCIDRs []string `json:"ssh_cidrs"`
+	}{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, err
+	}
+	return vars.SSHCIDRs, nil
+}
+
+// ParseCIDRs validates and normalizes the given CIDR ranges. A single IP
+// address is converted to a range which only contains that address.
+func ParseCIDRs(cidrs []string) ([]string, error) {
+	parsed := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if ip := net.ParseIP(cidr); ip != nil {
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %v", cidr)
+		}
+		if !util.StringInSlice(ipNet.String(), parsed) {
+			parsed = append(parsed, ipNet.String())
+		}
+	}
+	if len(parsed) == 0 {
+		return nil, ErrEmptyAllowList
+	}
+	return parsed, nil
+}
+
+// allowSSHTerraform updates the SSH allow-list of the darknode and applies it to
+// both the cloud firewall and ufw on the host. The allow-list will be reverted
+// if terraform fails to apply the changes.
+func allowSSHTerraform(name string, env []string, cidrs []string) error {
+	if !isFirewallManaged(name) {
+		return ErrFirewallNotManaged
+	}
+	path := sshAllowListFile(name)
+	old, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := writeSSHAllowList(name, cidrs); err != nil {
+		return err
+	}
+
+	color.Green("[%v] updating the firewall ...", name)
+	if err := util.NewTerraform(name, env).Apply(); err != nil {
+		// revert the allow-list if fail to apply the changes
+		var revertErr error
+		if old == nil {
+			revertErr = os.Remove(path)
+		} else {
+			revertErr = ioutil.WriteFile(path, old, 0600)
+		}
+		if revertErr != nil {
+			fmt.Println("fail to revert the change to `terraform.tfvars.json` file")
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+func TestAllowSSHTerraform(t *testing.T) {
+	const mainTf = `variable "ssh_cidrs" {
+  type = list(string)
+}
+`
+	fake := setupNode(t, "test", mainTf)
+	if err := allowSSHTerraform("test", nil, []string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	cidrs, err := SSHAllowList("test")
+	if err != nil || len(cidrs) != 1 || cidrs[0] != "10.0.0.0/8" {
+		t.Fatalf("unexpected allow-list %v, %v", cidrs, err)
+	}
+
+	// A failed apply is returned and the allow-list is reverted.
+	fake.Errs["apply"] = &util.TerraformError{Name: "test", Command: "apply", Err: util.ErrApplyFailed}
+	if err := allowSSHTerraform("test", nil, []string{"1.2.3.4/32"}); !errors.Is(err, util.ErrApplyFailed) {
+		t.Fatalf("expected %v, got %v", util.ErrApplyFailed, err)
+	}
+	cidrs, err = SSHAllowList("test")
+	if err != nil || len(cidrs) != 1 || cidrs[0] != "10.0.0.0/8" {
+		t.Fatalf("allow-list is not reverted: %v, %v", cidrs, err)
+	}
+
+	// The allow-list is removed if there was none before.
+	if err := os.Remove(sshAllowListFile("test")); err != nil {
+		t.Fatal(err)
+	}
+	if err := allowSSHTerraform("test", nil, []string{"1.2.3.4/32"}); !errors.Is(err, util.ErrApplyFailed) {
+		t.Fatalf("expected %v, got %v", util.ErrApplyFailed, err)
+	}
+	if _, err := ioutil.ReadFile(sshAllowListFile("test")); !os.IsNotExist(err) {
+		t.Fatalf("allow-list is not removed: %v", err)
+	}
+}
+
+func TestMigrateFirewall(t *testing.T) {
+	setupNode(t, "legacy-aws", "")
+	copyFixture(t, "legacy-aws", "legacy-aws")
+	if _, err := SSHAllowList("legacy-aws"); err != ErrFirewallNotManaged {
+		t.Fatalf("expected %v, got %v", ErrFirewallNotManaged, err)
+	}
+
+	changed, err := MigrateFirewall("legacy-aws")
+	if err != nil || !changed {
+		t.Fatalf("firewall is not migrated, changed = %v, err = %v", changed, err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(util.NodePath("legacy-aws"), "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf := string(data)
+	for _, expected := range []string{
+		`for_each = length(local.ssh_cidrs_ipv4) > 0 ? [local.ssh_cidrs_ipv4] : []`,
+		`ipv6_cidr_blocks = ingress.value`,
+		`resource "null_resource" "ufw"`,
+		`host        = aws_instance.darknode.public_ip`,
+		`private_key = file("~/.darknode/darknodes/legacy-aws/ssh_keypair")`,
+	} {
+		if !strings.Contains(tf, expected) {
+			t.Errorf("main.tf does not contain %q", expected)
+		}
+	}
+	// Only the SSH rule is replaced, the darknode ports stay open.
+	if strings.Count(tf, `cidr_blocks = ["0.0.0.0/0"]`) != 2 {
+		t.Errorf("unexpected rules of the security group\n%v", tf)
+	}
+	cidrs, err := SSHAllowList("legacy-aws")
+	if err != nil || strings.Join(cidrs, ",") != strings.Join(DefaultSSHAllowList, ",") {
+		t.Errorf("unexpected allow-list %v, %v", cidrs, err)
+	}
+
+	// Running it again changes nothing.
+	if changed, err := MigrateFirewall("legacy-aws"); err != nil || changed {
+		t.Errorf("firewall is migrated again, changed = %v, err = %v", changed, err)
+	}
+}
+
+func TestMigrateFirewallGcp(t *testing.T) {
+	const mainTf = `resource "google_compute_firewall" "darknode_firewall" {
+  network     = "${google_compute_network.darknode_network.name}"
+  name        = "legacy-gcp"
+
+  allow{
+	protocol = "tcp"
+    ports = ["22", "18514", "18515"]
+  }
+
+  target_tags   = ["darknode"]
+}
+
+output "provider" {
+  value = "gcp"
+}`
+	setupNode(t, "legacy-gcp", mainTf)
+	if changed, err := MigrateFirewall("legacy-gcp"); err != nil || !changed {
+		t.Fatalf("firewall is not migrated, changed = %v, err = %v", changed, err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(util.NodePath("legacy-gcp"), "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf := string(data)
+	for _, expected := range []string{
+		`ports = ["18514", "18515"]`,
+		`name          = "legacy-gcp-ssh"`,
+		`source_ranges = local.ssh_cidrs_ipv4`,
+		`host        = google_compute_instance.darknode.network_interface[0].access_config[0].nat_ip`,
+	} {
+		if !strings.Contains(tf, expected) {
+			t.Errorf("main.tf does not contain %q", expected)
+		}
+	}
+
+	// A config whose SSH rule has been changed manually is left alone.
+	setupNode(t, "custom", strings.Replace(mainTf, `"22", `, "", 1))
+	if _, err := MigrateFirewall("custom"); err == nil {
+		t.Error("config without the SSH rule is migrated")
+	}
+}
@@ -5,61 +5,52 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/renproject/darknode-cli/darknode"
+	"github.com/fatih/color"
 	"github.com/renproject/darknode-cli/util"
 	"github.com/urfave/cli"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/option"
 )
 
 var ErrInsufficientPermission = errors.New("insufficient permissions")
 
-type GcpRegion struct {
-	region string
-	zones  []string
-}
-
-// AllGcpRegions
-var AllGcpRegions = []GcpRegion{
-	{"asia-east1", []string{"a", "b", "c"}},
-	{"asia-east2", []string{"a", "b", "c"}},
-	{"asia-northeast1", []string{"a", "b", "c"}},
-	{"asia-northeast2", []string{"a", "b", "c"}},
-	{"asia-south1", []string{"a", "b", "c"}},
-	{"asia-southeast1", []string{"a", "b", "c"}},
-	{"australia-southeast1", []string{"a", "b", "c"}},
-	{"europe-north1", []string{"a", "b", "c"}},
-	{"europe-west1", []string{"b", "c", "d"}},
-	{"europe-west2", []string{"a", "b", "c"}},
-	{"europe-west3", []string{"a", "b", "c"}},
-	{"europe-west4", []string{"a", "b", "c"}},
-	{"europe-west6", []string{"a", "b", "c"}},
-	{"northamerica-northeast1", []string{"a", "b", "c"}},
-	{"southamerica-east1", []string{"a", "b", "c"}},
-	{"us-central1", []string{"a", "b", "c", "f"}},
-	{"us-east1", []string{"b", "c", "d"}},
-	{"us-east4", []string{"a", "b", "c"}},
-	{"us-west1", []string{"a", "b", "c"}},
-	{"us-west2", []string{"a", "b", "c"}},
-}
+// ErrUnknownGcpProject is returned when the project cannot be inferred from the
+// credentials and user doesn't provide one.
+var ErrUnknownGcpProject = errors.New("cannot infer the project from the credentials, please provide one with --gcp-project")
+
+// Regex for finding the machine type and zone in the terraform config.
+var (
+	RegexGcp = `machine_type\s+=\s+"(?P<instance>.+)"`
+
+	RegexGcpZone = `provider\s+"google"\s*\{[^}]*?\bzone\s*=\s*"(?P<region>[^"]+)"`
+)
 
 type providerGcp struct {
 	credFile string
+	project  string
 }
 
+// NewGcp returns a new GCP provider. It uses the service account credential
+// file if given, otherwise the Application Default Credentials will be used,
+// e.g. from `gcloud auth application-default login`, workload identity or the
+// metadata server.
 func NewGcp(ctx *cli.Context) (Provider, error) {
 	credFile := ctx.String("gcp-credentials")
-	if _, err := os.Stat(credFile); err != nil {
-		return nil, err
+	if credFile != "" {
+		if _, err := os.Stat(credFile); err != nil {
+			return nil, err
+		}
 	}
 	return providerGcp{
 		credFile: credFile,
+		project:  strings.TrimSpace(ctx.String("gcp-project")),
 	}, nil
 }
 
@@ -67,53 +58,190 @@ func (p providerGcp) Name() string {
 	return NameGcp
 }
 
-func (p providerGcp) Deploy(ctx *cli.Context) error {
-	name := ctx.String("name")
-	tags := ctx.String("tags")
-	config := ctx.String("config")
+// env returns the environment variables for passing the credentials to terraform.
+func (p providerGcp) env() []string {
+	if p.credFile == "" {
+		return nil
+	}
+	return []string{"GOOGLE_APPLICATION_CREDENTIALS=" + p.credFile}
+}
 
-	latestVersion, err := util.LatestStableRelease()
-	if err != nil {
-		return err
+func (p providerGcp) Placements(zones []string, machine string, count int) ([]string, error) {
+	if _, err := p.projectID(); err != nil {
+		return nil, err
 	}
-	projectID, err := p.projectID()
+	available, err := p.Regions()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	zone, machine, err := p.validateZoneAndMachine(ctx)
+	zones, err = selectRegions(zones, available, count)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	for _, zone := range zones {
+		machines, err := p.InstanceTypes(zone)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateMachineType(machine, zone, instanceTypeNames(machines)); err != nil {
+			return nil, err
+		}
+	}
+	return zones, nil
+}
 
-	// Initialization
-	network, err := darknode.NewNetwork(ctx.String("network"))
+func (p providerGcp) Deploy(d Deployment) error {
+	projectID, err := p.projectID()
 	if err != nil {
 		return err
 	}
-	if err := initNode(name, tags, network, config); err != nil {
+	if err := p.tfConfig(d.Name, projectID, d.Region, d.Instance, d.Version, d.Image, d.IPv6, d.StaticIP, d.Spot); err != nil {
 		return err
 	}
-
-	// Generate terraform config and start deploying
-	if err := p.tfConfig(name, projectID, zone, machine, latestVersion); err != nil {
+	if err := runTerraform(d.Name, p.env(), d.Quiet); err != nil {
 		return err
 	}
-	if err := runTerraform(name); err != nil {
+	if err := deployed(d); err != nil {
 		return err
 	}
+	return waitForReady(d.Name)
+}
 
-	return outputURL(name)
+func (p providerGcp) Destroy(name string) error {
+	return destroyTerraform(name, p.env())
 }
 
-func (p providerGcp) projectID() (string, error) {
+func (p providerGcp) Resize(name, machine string) error {
+	replacement := fmt.Sprintf(`machine_type = "%v"`, machine)
+	return resizeTerraform(name, p.env(), RegexGcp, replacement)
+}
+
+func (p providerGcp) Status(name string) (Status, error) {
+	return statusTerraform(name)
+}
+
+func (p providerGcp) AllowSSH(name string, cidrs []string) error {
+	return allowSSHTerraform(name, p.env(), cidrs)
+}
+
+func (p providerGcp) Recover(name string) (bool, error) {
+	return recoverTerraform(name, p.env(), "google_compute_instance")
+}
+
+func (p providerGcp) Instance(name string) (string, string, error) {
+	return instanceTerraform(name, RegexGcpZone, RegexGcp)
+}
+
+// Regions returns all the zones in GCP which are up, as darknodes are deployed
+// to a zone instead of a region.
+func (p providerGcp) Regions() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	service, projectID, err := p.computeService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make([]string, 0)
+	err = service.Zones.List(projectID).Pages(ctx, func(list *compute.ZoneList) error {
+		for _, zone := range list.Items {
+			if zone.Status == "UP" {
+				zones = append(zones, zone.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// InstanceTypes returns all the machine types available in the given zone. The
+// Compute API doesn't give the price of machine types.
+func (p providerGcp) InstanceTypes(zone string) ([]InstanceType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	service, projectID, err := p.computeService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	machines := make([]InstanceType, 0)
+	err = service.MachineTypes.List(projectID, zone).Pages(ctx, func(list *compute.MachineTypeList) error {
+		for _, machine := range list.Items {
+			if machine.Deprecated == nil || machine.Deprecated.State == "" {
+				machines = append(machines, InstanceType{
+					Name:   machine.Name,
+					CPUs:   int(machine.GuestCpus),
+					Memory: float64(machine.MemoryMb) / 1024,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(machines, func(i, j int) bool {
+		return machines[i].Name < machines[j].Name
+	})
+	return machines, nil
+}
+
+// credentials reads the service account credentials from the credential file,
+// or finds the Application Default Credentials if no file is given.
+func (p providerGcp) credentials(ctx context.Context) (*google.Credentials, error) {
+	scope := "https://www.googleapis.com/auth/cloud-platform"
+	if p.credFile == "" {
+		return google.FindDefaultCredentials(ctx, scope)
+	}
 	data, err := ioutil.ReadFile(p.credFile)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return google.CredentialsFromJSON(ctx, data, scope)
+}
+
+// projectOf returns the project given by user, or the project inferred from the
+// credentials.
+func (p providerGcp) projectOf(creds *google.Credentials) (string, error) {
+	if p.project != "" {
+		return p.project, nil
+	}
+	if creds.ProjectID == "" {
+		return "", ErrUnknownGcpProject
+	}
+	return creds.ProjectID, nil
+}
+
+// computeService returns a client of the Compute API and the project ID.
+func (p providerGcp) computeService(ctx context.Context) (*compute.Service, string, error) {
+	creds, err := p.credentials(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	projectID, err := p.projectOf(creds)
+	if err != nil {
+		return nil, "", err
 	}
+	service, err := compute.NewService(ctx, option.WithCredentials(creds))
+	if err != nil {
+		return nil, "", err
+	}
+	return service, projectID, nil
+}
+
+func (p providerGcp) projectID() (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	creds, error := google.CredentialsFromJSON(ctx, data, "https://www.googleapis.com/auth/cloud-platform")
-	if error != nil {
+	creds, err := p.credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	projectID, err := p.projectOf(creds)
+	if err != nil {
 		return "", err
 	}
 	service, err := cloudresourcemanager.NewService(ctx, option.WithCredentials(creds))
@@ -121,29 +249,75 @@ func (p providerGcp) projectID() (string, error) {
 		return "", err
 	}
 
+	permissions := GcpPermissions()
 	rb := &cloudresourcemanager.TestIamPermissionsRequest{
-		Permissions: []string{"compute.instances.create", "compute.networks.create", "compute.firewalls.create"}, ForceSendFields: nil, NullFields: nil,
+		Permissions: permissions,
 	}
-	resp, err := service.Projects.TestIamPermissions(creds.ProjectID, rb).Context(ctx).Do()
+	resp, err := service.Projects.TestIamPermissions(projectID, rb).Context(ctx).Do()
 	if err != nil {
 		return "", err
 	}
-	if len(resp.Permissions) < 3 {
+	missing := make([]string, 0)
+	for _, permission := range permissions {
+		if !util.StringInSlice(permission, resp.Permissions) {
+			missing = append(missing, permission)
+		}
+	}
+	if len(missing) > 0 {
+		color.Red("The Google Cloud credentials are missing below permissions in [%v] for deploying the darknode:", projectID)
+		for _, permission := range missing {
+			color.Red(permission)
+		}
 		return "", ErrInsufficientPermission
 	}
-	return creds.ProjectID, nil
+	return projectID, nil
 }
 
-func (p providerGcp) validateZoneAndMachine(ctx *cli.Context) (string, string, error) {
-	zone := strings.ToLower(strings.TrimSpace(ctx.String("gcp-zone")))
-	machine := strings.ToLower(strings.TrimSpace(ctx.String("gcp-machine")))
+// gcpResourcePermissions are the IAM permissions needed by terraform to create,
+// update and destroy each type of resource in the GCP template.
+var gcpResourcePermissions = map[string][]string{
+	"google_compute_network": {
+		"compute.networks.create", "compute.networks.delete", "compute.networks.get",
+		"compute.globalOperations.get",
+	},
+	"google_compute_subnetwork": {
+		"compute.subnetworks.create", "compute.subnetworks.delete", "compute.subnetworks.get",
+		"compute.subnetworks.use", "compute.subnetworks.useExternalIp", "compute.regionOperations.get",
+	},
+	"google_compute_firewall": {
+		"compute.firewalls.create", "compute.firewalls.delete", "compute.firewalls.get",
+		"compute.firewalls.update", "compute.networks.updatePolicy", "compute.globalOperations.get",
+	},
+	"google_compute_address": {
+		"compute.addresses.create", "compute.addresses.delete", "compute.addresses.get",
+		"compute.addresses.use", "compute.regionOperations.get",
+	},
+	"google_compute_instance": {
+		"compute.instances.create", "compute.instances.delete", "compute.instances.get",
+		"compute.instances.start", "compute.instances.stop", "compute.instances.setMachineType",
+		"compute.instances.setMetadata", "compute.instances.setTags", "compute.instances.setScheduling",
+		"compute.instances.setServiceAccount", "compute.disks.create", "compute.images.useReadOnly",
+		"compute.networks.use", "compute.networks.useExternalIp", "compute.subnetworks.use",
+		"compute.subnetworks.useExternalIp", "compute.zoneOperations.get", "iam.serviceAccounts.actAs",
+	},
+	"null_resource": nil,
+}
 
-	// Select a random zone for user if they don't provide one.
-	if zone == "" {
-		region := AllGcpRegions[rand.Intn(len(AllGcpRegions))]
-		return fmt.Sprintf("%v-%v", region.region, region.zones[rand.Intn(len(region.zones))]), machine, nil
-	}
+// GcpPermissions returns the IAM permissions needed by terraform to manage all
+// the resources in the GCP template, including the ones which are only created
+// with some of the options, e.g. the static IP.
+func GcpPermissions() []string {
+	return permissionsOf(gcpTemplate+ufwResource("", "", "", ""), gcpResourcePermissions)
+}
 
-	// todo : validate the zone and machine type
-	return zone, machine, nil
+// validateMachineType validates whether the machine type is available in the zone.
+func validateMachineType(machine, zone string, machines []string) error {
+	if !util.StringInSlice(machine, machines) {
+		fmt.Printf("[%v] is the selected zone.\n", zone)
+		fmt.Printf("Your project can only create below machine types in [%v]:\n", zone)
+		fmt.Println(strings.Join(machines, ", "))
+		fmt.Println("You can find more details about these machine types from https://cloud.google.com/compute/docs/machine-types")
+		return ErrInstanceTypeNotAvailable
+	}
+	return nil
 }
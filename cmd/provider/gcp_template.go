@@ -2,54 +2,60 @@ package provider
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 	"text/template"
 
 	"github.com/renproject/darknode-cli/util"
 )
 
 type gcpTerraform struct {
-	Name           string
-	CredentialFile string
-	Project        string
-	Zone           string
-	MachineType    string
-	ConfigPath     string
-	PubKeyPath     string
-	PriKeyPath     string
-	ServiceFile    string
-	LatestVersion  string
+	Name         string
+	Project      string
+	Zone         string
+	Region       string
+	MachineType  string
+	PubKeyPath   string
+	UserDataPath string
+	Image        string
+	ImageName    string
+	IPv6         bool
+	StaticIP     bool
+	Spot         bool
+	Firewall     string
+	Config       string
 }
 
-func (p providerGcp) tfConfig(name, project, zone, machine, latestVersion string) error {
-	tf := gcpTerraform{
-		Name:           name,
-		CredentialFile: p.credFile,
-		Project:        project,
-		Zone:           zone,
-		MachineType:    machine,
-		ConfigPath:     fmt.Sprintf("~/.darknode/darknodes/%v/config.json", name),
-		PubKeyPath:     fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair.pub", name),
-		PriKeyPath:     fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name),
-		ServiceFile:    darknodeService,
-		LatestVersion:  latestVersion,
+func (p providerGcp) tfConfig(name, project, zone, machine, latestVersion string, image Image, ipv6, staticIP, spot bool) error {
+	if err := writeUserData(name, latestVersion); err != nil {
+		return err
 	}
 
-	t, err := template.New("gcp").Parse(gcpTemplate)
-	if err != nil {
-		return err
+	tf := gcpTerraform{
+		Name:         name,
+		Project:      project,
+		Zone:         zone,
+		Region:       zone[:strings.LastIndex(zone, "-")],
+		MachineType:  machine,
+		PubKeyPath:   fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair.pub", name),
+		UserDataPath: fmt.Sprintf("~/.darknode/darknodes/%v/user-data.yaml", name),
+		Image:        image.Name,
+		ImageName:    image.Gcp,
+		IPv6:         ipv6,
+		StaticIP:     staticIP,
+		Spot:         spot,
+		Firewall:     sshVariable + ufwResource("google_compute_instance.darknode.id", "google_compute_instance.darknode.network_interface[0].access_config[0].nat_ip", `"ubuntu"`, fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name)),
+		Config:       configResource("google_compute_instance.darknode.id", "google_compute_instance.darknode.network_interface[0].access_config[0].nat_ip", name),
 	}
-	tfFile, err := os.Create(filepath.Join(util.NodePath(name), "main.tf"))
+
+	t, err := template.New(NameGcp).Parse(gcpTemplate)
 	if err != nil {
 		return err
 	}
-	return t.Execute(tfFile, tf)
+	return writeTerraformConfig(name, util.NodePath(name), t, tf)
 }
 
 var gcpTemplate = `
 provider "google" {
-  credentials = file("{{.CredentialFile}}")
   project     = "{{.Project}}"
   zone        = "{{.Zone}}"
 }
@@ -59,9 +65,56 @@ provider "google" {
 *******************/
 
 resource "google_compute_network" "darknode_network" {
-  name = "{{.Name}}"
+  name = "{{.Name}}"{{if .IPv6}}
+  auto_create_subnetworks = false{{end}}
 }
+{{if .IPv6}}
+resource "google_compute_subnetwork" "darknode_subnetwork" {
+  name             = "{{.Name}}"
+  network          = google_compute_network.darknode_network.id
+  region           = "{{.Region}}"
+  ip_cidr_range    = "10.0.0.0/24"
+  stack_type       = "IPV4_IPV6"
+  ipv6_access_type = "EXTERNAL"
+}
+
+// Firewall rules cannot mix IPv4 and IPv6 source ranges.
+resource "google_compute_firewall" "darknode_firewall_ipv6" {
+  network       = google_compute_network.darknode_network.name
+  name          = "{{.Name}}-ipv6"
+  source_ranges = ["::/0"]
+
+  allow {
+    protocol = "58" // ICMPv6
+  }
 
+  allow {
+    protocol = "tcp"
+    ports    = ["18514", "18515"]
+  }
+
+  target_tags = ["darknode"]
+}
+
+resource "google_compute_firewall" "darknode_ssh_ipv6" {
+  count         = length(local.ssh_cidrs_ipv6) > 0 ? 1 : 0
+  network       = google_compute_network.darknode_network.name
+  name          = "{{.Name}}-ssh-ipv6"
+  source_ranges = local.ssh_cidrs_ipv6
+
+  allow {
+    protocol = "tcp"
+    ports    = ["22"]
+  }
+
+  target_tags = ["darknode"]
+}
+{{end}}{{if .StaticIP}}
+resource "google_compute_address" "darknode" {
+  name   = "{{.Name}}"
+  region = "{{.Region}}"
+}
+{{end}}
 resource "google_compute_firewall" "darknode_firewall" {
   network     = "${google_compute_network.darknode_network.name}"
   name        = "{{.Name}}"
@@ -72,12 +125,26 @@ resource "google_compute_firewall" "darknode_firewall" {
 
   allow{
 	protocol = "tcp"
-    ports = ["22", "18514", "18515"]
+    ports = ["18514", "18515"]
   }	
 
   target_tags   = ["darknode"]
 }
 
+resource "google_compute_firewall" "darknode_ssh" {
+  count         = length(local.ssh_cidrs_ipv4) > 0 ? 1 : 0
+  network       = google_compute_network.darknode_network.name
+  name          = "{{.Name}}-ssh"
+  source_ranges = local.ssh_cidrs_ipv4
+
+  allow {
+    protocol = "tcp"
+    ports    = ["22"]
+  }
+
+  target_tags = ["darknode"]
+}
+
 /***********
 **** VM ****
 ***********/
@@ -89,17 +156,42 @@ resource "google_compute_instance" "darknode" {
 
   boot_disk {
 	initialize_params {
-	  image = "ubuntu-os-cloud/ubuntu-1804-lts"
+	  image = "{{.ImageName}}"
     }
   }
 
   scheduling {
+{{- if .Spot}}
+    preemptible                 = true
+    automatic_restart           = false
+    on_host_maintenance         = "TERMINATE"
+    provisioning_model          = "SPOT"
+    instance_termination_action = "DELETE"
+{{- else}}
     on_host_maintenance = "MIGRATE"
+{{- end}}
   }
 
   network_interface {
+{{- if .IPv6}}
+    subnetwork = google_compute_subnetwork.darknode_subnetwork.id
+    stack_type = "IPV4_IPV6"
+    access_config {
+{{- if .StaticIP}}
+      nat_ip = google_compute_address.darknode.address
+{{- end}}
+    }
+    ipv6_access_config {
+      network_tier = "PREMIUM"
+    }
+{{- else}}
     network = "${google_compute_network.darknode_network.name}"
-    access_config {}
+    access_config {
+{{- if .StaticIP}}
+      nat_ip = google_compute_address.darknode.address
+{{- end}}
+    }
+{{- end}}
   }
 
   service_account {
@@ -109,80 +201,37 @@ resource "google_compute_instance" "darknode" {
   tags = ["darknode"]
 
   metadata = {
-    ssh-keys = "ubuntu:${file("{{.PubKeyPath}}")}"
-  }
-
-  provisioner "remote-exec" {
-
-	inline = [
-      "set -x",
-      "until sudo apt update; do sleep 4; done",
-      "sudo adduser darknode --gecos \",,,\" --disabled-password",
-      "sudo rsync --archive --chown=darknode:darknode ~/.ssh /home/darknode",
-      "sudo DEBIAN_FRONTEND=noninteractive apt-get -y update",
-      "sudo DEBIAN_FRONTEND=noninteractive apt-get -y upgrade",
-      "sudo DEBIAN_FRONTEND=noninteractive apt-get -y dist-upgrade",
-      "sudo DEBIAN_FRONTEND=noninteractive apt-get -y autoremove",
-      "until sudo apt-get install ufw; do sleep 4; done",
-      "sudo ufw limit 22/tcp",
-      "sudo ufw allow 18514/tcp", 
-      "sudo ufw allow 18515/tcp", 
-      "sudo ufw --force enable",
-	]
-
-    connection {
-      type        = "ssh"
-      user        = "ubuntu"
-      private_key = file("{{.PriKeyPath}}")
-      host        = self.network_interface[0].access_config[0].nat_ip
-    }
+    ssh-keys  = "ubuntu:${file("{{.PubKeyPath}}")}"
+    user-data = file("{{.UserDataPath}}")
   }
 
-  provisioner "file" {
-
-    source      = "{{.ConfigPath}}"
-    destination = "$HOME/config.json"
-
-    connection {
-      type        = "ssh"
-      user        = "darknode"
-      private_key = file("{{.PriKeyPath}}")
-      host        = self.network_interface[0].access_config[0].nat_ip
-    }
-  }
-
-  provisioner "remote-exec" {
-	
-	inline = [
-      "set -x",
-	  "mkdir -p $HOME/.darknode/bin",
-      "mkdir -p $HOME/.config/systemd/user",
-      "mv $HOME/config.json $HOME/.darknode/config.json",
-	  "curl -sL https://www.github.com/renproject/darknode-release/releases/download/{{.LatestVersion}}/darknode > ~/.darknode/bin/darknode",
-	  "chmod +x ~/.darknode/bin/darknode",
-      "echo {{.LatestVersion}} > ~/.darknode/version",
-	  <<EOT
-	  echo "{{.ServiceFile}}" > ~/.config/systemd/user/darknode.service
-      EOT
-      ,
-	  "loginctl enable-linger darknode",
-      "systemctl --user enable darknode.service",
-      "systemctl --user start darknode.service",
-	]
-
-    connection {
-      type        = "ssh"
-      user        = "darknode"
-      private_key = file("{{.PriKeyPath}}")
-      host        = self.network_interface[0].access_config[0].nat_ip
-    }
+  // A newer Ubuntu image must not replace a running darknode.
+  lifecycle {
+    ignore_changes = [boot_disk[0].initialize_params[0].image]
   }
 }
 
+{{.Firewall}}{{.Config}}
 output "provider" {
   value = "gcp"
 }
 
+output "image" {
+  value = "{{.Image}}"
+}
+
+output "spot" {
+  value = {{.Spot}}
+}
+
 output "ip" {
+{{- if .StaticIP}}
+  value = google_compute_address.darknode.address
+{{- else}}
   value = "${google_compute_instance.darknode.network_interface[0].access_config[0].nat_ip}"
-}`
+{{- end}}
+}
+{{if .IPv6}}
+output "ipv6" {
+  value = google_compute_instance.darknode.network_interface[0].ipv6_access_config[0].external_ipv6
+}{{end}}`
@@ -0,0 +1,254 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/renproject/darknode-cli/util"
+	"github.com/urfave/cli"
+)
+
+// Regex for finding the server type and location in the terraform config.
+var (
+	RegexHetzner = `server_type\s+=\s*"(?P<instance>.+)"`
+
+	RegexHetznerLocation = `(?s)resource\s+"hcloud_server"\s+"darknode"\s*\{.*?\blocation\s*=\s*"(?P<region>[^"]+)"`
+)
+
+type providerHetzner struct {
+	token string
+}
+
+func NewHetzner(ctx *cli.Context) (Provider, error) {
+	token := ctx.String("hetzner-token")
+	if token == "" {
+		return nil, errors.New("please provide the Hetzner Cloud API token with --hetzner-token")
+	}
+
+	return providerHetzner{
+		token: token,
+	}, nil
+}
+
+func (p providerHetzner) Name() string {
+	return NameHetzner
+}
+
+// env returns the environment variables for passing the credentials to terraform.
+func (p providerHetzner) env() []string {
+	return []string{"HCLOUD_TOKEN=" + p.token}
+}
+
+func (p providerHetzner) Placements(locations []string, serverType string, count int) ([]string, error) {
+	available, err := p.Regions()
+	if err != nil {
+		return nil, err
+	}
+	locations, err = selectRegions(locations, available, count)
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		serverTypes, err := p.InstanceTypes(location)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateServerType(serverType, location, instanceTypeNames(serverTypes)); err != nil {
+			return nil, err
+		}
+	}
+	return locations, nil
+}
+
+func (p providerHetzner) Deploy(d Deployment) error {
+	if err := p.tfConfig(d.Name, d.Region, d.Instance, d.Version, d.Image); err != nil {
+		return err
+	}
+	if err := runTerraform(d.Name, p.env(), d.Quiet); err != nil {
+		return err
+	}
+	if err := deployed(d); err != nil {
+		return err
+	}
+	return waitForReady(d.Name)
+}
+
+func (p providerHetzner) Destroy(name string) error {
+	return destroyTerraform(name, p.env())
+}
+
+func (p providerHetzner) Resize(name, serverType string) error {
+	replacement := fmt.Sprintf(`server_type = "%v"`, serverType)
+	return resizeTerraform(name, p.env(), RegexHetzner, replacement)
+}
+
+func (p providerHetzner) Status(name string) (Status, error) {
+	return statusTerraform(name)
+}
+
+func (p providerHetzner) AllowSSH(name string, cidrs []string) error {
+	return allowSSHTerraform(name, p.env(), cidrs)
+}
+
+func (p providerHetzner) Recover(name string) (bool, error) {
+	return recoverTerraform(name, p.env(), "hcloud_server")
+}
+
+func (p providerHetzner) Instance(name string) (string, string, error) {
+	return instanceTerraform(name, RegexHetznerLocation, RegexHetzner)
+}
+
+func (p providerHetzner) Regions() ([]string, error) {
+	locations, err := p.locations()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(locations))
+	for i := range locations {
+		names[i] = locations[i].Name
+	}
+	return names, nil
+}
+
+// InstanceTypes returns all the server types available in the given location.
+// Prices are in EUR excluding VAT.
+func (p providerHetzner) InstanceTypes(location string) ([]InstanceType, error) {
+	serverTypes, err := p.serverTypes()
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]InstanceType, 0)
+	for _, serverType := range serverTypes {
+		price, ok := serverType.Price(location)
+		if !ok {
+			continue
+		}
+		instance := InstanceType{
+			Name:     serverType.Name,
+			CPUs:     serverType.Cores,
+			Memory:   serverType.Memory,
+			Currency: "EUR",
+		}
+		instance.PriceHourly, _ = strconv.ParseFloat(price.Hourly.Net, 64)
+		instance.PriceMonthly, _ = strconv.ParseFloat(price.Monthly.Net, 64)
+		instances = append(instances, instance)
+	}
+	if len(instances) == 0 {
+		return nil, ErrRegionNotAvailable
+	}
+	return instances, nil
+}
+
+// validateServerType validates whether the server type is available in the location.
+func validateServerType(serverType, location string, serverTypes []string) error {
+	if !util.StringInSlice(serverType, serverTypes) {
+		fmt.Printf("[%v] is the selected location.\n", location)
+		fmt.Printf("Your account can only create below server types in [%v]:\n", location)
+		for i := range serverTypes {
+			fmt.Println(serverTypes[i])
+		}
+		fmt.Println("You can find more details about these server types from https://www.hetzner.com/cloud")
+		return ErrInstanceTypeNotAvailable
+	}
+	return nil
+}
+
+// HetznerLocation is the json object of a location returned by the Hetzner Cloud API.
+type HetznerLocation struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Country     string `json:"country"`
+	City        string `json:"city"`
+	NetworkZone string `json:"network_zone"`
+}
+
+// HetznerServerType is the json object of a server type returned by the Hetzner Cloud API.
+type HetznerServerType struct {
+	Name       string         `json:"name"`
+	Cores      int            `json:"cores"`
+	Memory     float64        `json:"memory"`
+	Disk       int            `json:"disk"`
+	Deprecated bool           `json:"deprecated"`
+	Prices     []HetznerPrice `json:"prices"`
+}
+
+// HetznerPrice is the json object of the price of a server type in a location.
+type HetznerPrice struct {
+	Location string `json:"location"`
+	Hourly   struct {
+		Net   string `json:"net"`
+		Gross string `json:"gross"`
+	} `json:"price_hourly"`
+	Monthly struct {
+		Net   string `json:"net"`
+		Gross string `json:"gross"`
+	} `json:"price_monthly"`
+}
+
+// Price returns the price of the server type in the given location, and false if
+// the server type cannot be created in the location.
+func (serverType HetznerServerType) Price(location string) (HetznerPrice, bool) {
+	if serverType.Deprecated {
+		return HetznerPrice{}, false
+	}
+	for _, price := range serverType.Prices {
+		if price.Location == location {
+			return price, true
+		}
+	}
+	return HetznerPrice{}, false
+}
+
+// locations sends a GET request to Hetzner Cloud API to get all the locations.
+func (p providerHetzner) locations() ([]HetznerLocation, error) {
+	locations := struct {
+		Locations []HetznerLocation `json:"locations"`
+	}{}
+	if err := p.get("https://api.hetzner.cloud/v1/locations?per_page=50", &locations); err != nil {
+		return nil, err
+	}
+	return locations.Locations, nil
+}
+
+// serverTypes sends a GET request to Hetzner Cloud API to get all the server
+// types and the locations they are available in.
+func (p providerHetzner) serverTypes() ([]HetznerServerType, error) {
+	serverTypes := struct {
+		ServerTypes []HetznerServerType `json:"server_types"`
+	}{}
+	if err := p.get("https://api.hetzner.cloud/v1/server_types?per_page=50", &serverTypes); err != nil {
+		return nil, err
+	}
+	return serverTypes.ServerTypes, nil
+}
+
+// get sends an authorized GET request to the Hetzner Cloud API and unmarshals
+// the response into the given object.
+func (p providerHetzner) get(url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Check the response status code
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(string(data))
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+type hetznerTerraform struct {
+	Name         string
+	Location     string
+	ServerType   string
+	PubKeyPath   string
+	UserDataPath string
+	Image        string
+	ImageName    string
+	Firewall     string
+	Config       string
+}
+
+// tfConfig generates the terraform config file for deploying to Hetzner Cloud.
+func (p providerHetzner) tfConfig(name, location, serverType, latestVersion string, image Image) error {
+	if err := writeUserData(name, latestVersion); err != nil {
+		return err
+	}
+
+	tf := hetznerTerraform{
+		Name:         name,
+		Location:     location,
+		ServerType:   serverType,
+		PubKeyPath:   fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair.pub", name),
+		UserDataPath: fmt.Sprintf("~/.darknode/darknodes/%v/user-data.yaml", name),
+		Image:        image.Name,
+		ImageName:    image.Hetzner,
+		Firewall:     sshVariable + ufwResource("hcloud_server.darknode.id", "hcloud_server.darknode.ipv4_address", `"root"`, fmt.Sprintf("~/.darknode/darknodes/%v/ssh_keypair", name)),
+		Config:       configResource("hcloud_server.darknode.id", "hcloud_server.darknode.ipv4_address", name),
+	}
+
+	t, err := template.New(NameHetzner).Parse(hetznerTemplate)
+	if err != nil {
+		return err
+	}
+	return writeTerraformConfig(name, util.NodePath(name), t, tf)
+}
+
+var hetznerTemplate = `
+provider "hcloud" {}
+
+resource "hcloud_ssh_key" "darknode" {
+  name       = "{{.Name}}"
+  public_key = file("{{.PubKeyPath}}")
+}
+
+resource "hcloud_firewall" "darknode" {
+  name = "{{.Name}}"
+
+  rule {
+    direction  = "in"
+    protocol   = "tcp"
+    port       = "22"
+    source_ips = var.ssh_cidrs
+  }
+
+  rule {
+    direction  = "in"
+    protocol   = "tcp"
+    port       = "18514-18515"
+    source_ips = ["0.0.0.0/0", "::/0"]
+  }
+
+  rule {
+    direction  = "in"
+    protocol   = "icmp"
+    source_ips = ["0.0.0.0/0", "::/0"]
+  }
+}
+
+resource "hcloud_server" "darknode" {
+  name        = "{{.Name}}"
+  image       = "{{.ImageName}}"
+  location    = "{{.Location}}"
+  server_type = "{{.ServerType}}"
+  keep_disk   = true
+  user_data   = file("{{.UserDataPath}}")
+
+  firewall_ids = [hcloud_firewall.darknode.id]
+
+  ssh_keys = [
+    hcloud_ssh_key.darknode.id
+  ]
+}
+
+{{.Firewall}}{{.Config}}
+output "provider" {
+  value = "hetzner"
+}
+
+output "image" {
+  value = "{{.Image}}"
+}
+
+output "ip" {
+  value = hcloud_server.darknode.ipv4_address
+}
+
+output "ipv6" {
+  value = hcloud_server.darknode.ipv6_address
+}`
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// ErrUnsupportedImage is returned when the selected OS image is not one the
+// provisioning steps have been validated on.
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+// DefaultImage is the OS image darknodes are deployed on when no image is
+// specified.
+var DefaultImage = "ubuntu-24.04"
+
+// Image is an OS image the darknode provisioning steps (apt, ufw, loginctl
+// linger and systemd user units) have been validated on, together with the
+// name of the image on each provider.
+type Image struct {
+	Name    string
+	Aws     string // Name filter of the Canonical AMI
+	Do      string
+	Gcp     string
+	Hetzner string
+	Docker  string
+}
+
+// Images are all the supported OS images.
+var Images = []Image{
+	{
+		Name:    "ubuntu-24.04",
+		Aws:     "ubuntu/images/hvm-ssd-gp3/ubuntu-noble-24.04-amd64-server-*",
+		Do:      "ubuntu-24-04-x64",
+		Gcp:     "ubuntu-os-cloud/ubuntu-2404-lts-amd64",
+		Hetzner: "ubuntu-24.04",
+		Docker:  "ubuntu:24.04",
+	},
+	{
+		Name:    "ubuntu-22.04",
+		Aws:     "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*",
+		Do:      "ubuntu-22-04-x64",
+		Gcp:     "ubuntu-os-cloud/ubuntu-2204-lts",
+		Hetzner: "ubuntu-22.04",
+		Docker:  "ubuntu:22.04",
+	},
+}
+
+// parseImage returns the image selected by the `--image` flag, or the default
+// image if the flag is not set.
+func parseImage(ctx *cli.Context) (Image, error) {
+	return ParseImage(ctx.String("image"))
+}
+
+// ParseImage returns the image with given name, or the default image if the
+// name is empty.
+func ParseImage(name string) (Image, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = DefaultImage
+	}
+	for _, image := range Images {
+		if image.Name == name {
+			return image, nil
+		}
+	}
+
+	names := make([]string, len(Images))
+	for i, image := range Images {
+		names[i] = image.Name
+	}
+	return Image{}, fmt.Errorf("%v %v, supported images are: %v", ErrUnsupportedImage, name, strings.Join(names, ", "))
+}
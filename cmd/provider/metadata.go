@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/util"
+)
+
+// SyncMetadata updates the metadata of the darknode with the terraform outputs
+// after its resources have been changed, and applies the extra changes if
+// given.
+func SyncMetadata(name string, update func(meta *util.Metadata)) error {
+	outputs, err := util.NewTerraform(name, nil).Outputs()
+	if err != nil {
+		return err
+	}
+	return util.UpdateMetadata(name, func(meta *util.Metadata) {
+		meta.Provider = outputs.String("provider")
+		meta.IP = outputs.IP()
+		if image := outputs.String("image"); image != "" {
+			meta.Image = image
+		}
+		if spot := outputs.String("spot"); spot != "" {
+			meta.Spot = spot == "true"
+		}
+		if port, err := strconv.Atoi(outputs.String("ssh_port")); err == nil {
+			meta.SSHPort = port
+		}
+		if update != nil {
+			update(meta)
+		}
+	})
+}
+
+// deployed records the region, instance type and darknode version of a newly
+// deployed darknode in its metadata.
+func deployed(d Deployment) error {
+	return SyncMetadata(d.Name, func(meta *util.Metadata) {
+		meta.Region = d.Region
+		meta.Instance = d.Instance
+		meta.IPv6 = d.IPv6
+		meta.StaticIP = d.StaticIP
+		meta.Spot = d.Spot
+		meta.DarknodeVersion = d.Version
+	})
+}
+
+// darknodeVersion returns the version running on the darknode, it can be
+// replaced in tests.
+var darknodeVersion = util.Version
+
+func init() {
+	util.CreateLegacyMetadata = CreateMetadata
+}
+
+// CreateMetadata creates the metadata of a darknode deployed by an older
+// version of the CLI from the `tags.out` file, the terraform outputs and config,
+// and the version running on the darknode. The `tags.out` file is removed
+// afterwards. It returns whether the metadata has been created.
+func CreateMetadata(name string) (bool, error) {
+	if _, err := os.Stat(util.MetadataPath(name)); err == nil {
+		return false, nil
+	}
+
+	path := util.NodePath(name)
+	info, err := os.Stat(filepath.Join(path, "config.json"))
+	if err != nil {
+		return false, err
+	}
+	network, err := util.Network(name)
+	if err != nil {
+		return false, err
+	}
+	tags, err := ioutil.ReadFile(filepath.Join(path, "tags.out"))
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	outputs, err := util.NewTerraform(name, nil).Outputs()
+	if err != nil {
+		return false, err
+	}
+	p, err := providerByName(outputs.String("provider"))
+	if err != nil {
+		return false, fmt.Errorf("cannot find the provider of [%v], err = %v", name, err)
+	}
+
+	meta := util.Metadata{
+		Name:      name,
+		Network:   network,
+		Provider:  p.Name(),
+		IP:        outputs.IP(),
+		Tags:      legacyTags(name, string(tags)),
+		Image:     outputs.String("image"),
+		IPv6:      outputs.String("ipv6") != "",
+		StaticIP:  hasStaticIP(name),
+		Spot:      outputs.String("spot") == "true",
+		CreatedAt: info.ModTime().UTC(),
+	}
+	if region, instance, err := p.Instance(name); err == nil {
+		meta.Region, meta.Instance = region, instance
+	}
+	if version := darknodeVersion(name); version != "unknown" {
+		meta.DarknodeVersion = version
+	}
+	if err := util.WriteMetadata(meta); err != nil {
+		return false, err
+	}
+	if err := os.Remove(filepath.Join(path, "tags.out")); err != nil && !os.IsNotExist(err) {
+		return true, err
+	}
+	return true, nil
+}
+
+// legacyTags parses the free-form tags of a darknode deployed by an older
+// version of the CLI. Tags which are not allowed anymore are sanitised, or
+// dropped if nothing is left of them, with a warning.
+func legacyTags(name, tags string) []string {
+	var valid []string
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if util.ValidateTag(tag) == nil {
+			valid = append(valid, tag)
+			continue
+		}
+		if sanitised := util.SanitizeTag(tag); sanitised != "" {
+			color.Yellow("[%v] tag [%v] is not allowed anymore, it's renamed to [%v]", name, tag, sanitised)
+			valid = append(valid, sanitised)
+		} else {
+			color.Yellow("[%v] tag [%v] is not allowed anymore, it's removed", name, tag)
+		}
+	}
+	tagSet, _ := util.ParseTags(strings.Join(valid, ","))
+	return tagSet
+}
+
+// regexStaticIP matches the static IP resources of all the providers.
+var regexStaticIP = regexp.MustCompile(`resource\s+"(aws_eip|digitalocean_reserved_ip|google_compute_address)"`)
+
+// hasStaticIP returns whether the terraform config of the darknode allocates a
+// static IP.
+func hasStaticIP(name string) bool {
+	tf, err := ioutil.ReadFile(filepath.Join(util.NodePath(name), "main.tf"))
+	return err == nil && regexStaticIP.Match(tf)
+}
@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/renproject/darknode-cli/darknode"
+	"github.com/renproject/darknode-cli/util"
+)
+
+// copyFixture copies the node directory in testdata to the darknodes directory.
+func copyFixture(t *testing.T, fixture, name string) {
+	files, err := ioutil.ReadDir(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(util.NodePath(name), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", fixture, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(util.NodePath(name), f.Name()), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateMetadata(t *testing.T) {
+	fake := setupNode(t, "legacy-aws", "")
+	fake.Values = util.Outputs{"provider": "aws", "ip": "3.250.1.2"}
+	copyFixture(t, "legacy-aws", "legacy-aws")
+
+	oldVersion := darknodeVersion
+	darknodeVersion = func(name string) string { return "0.2.9" }
+	defer func() { darknodeVersion = oldVersion }()
+
+	created, err := CreateMetadata("legacy-aws")
+	if err != nil || !created {
+		t.Fatalf("cannot migrate the darknode, created = %v, err = %v", created, err)
+	}
+
+	meta, err := util.ReadMetadata("legacy-aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Version != util.MetadataVersion || meta.Name != "legacy-aws" || meta.Network != darknode.Mainnet ||
+		meta.Provider != NameAws || meta.IP != "3.250.1.2" || meta.DarknodeVersion != "0.2.9" || meta.CreatedAt.IsZero() {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if meta.Region != "eu-west-1" || meta.Instance != "t3.micro" {
+		t.Fatalf("unexpected region and instance type %v, %v", meta.Region, meta.Instance)
+	}
+	if want := []string{"canary", "eu-team", "mainnet"}; !reflect.DeepEqual(meta.Tags, want) {
+		t.Fatalf("expected tags %v, got %v", want, meta.Tags)
+	}
+	if _, err := os.Stat(filepath.Join(util.NodePath("legacy-aws"), "tags.out")); !os.IsNotExist(err) {
+		t.Fatalf("tags.out is not removed, err = %v", err)
+	}
+	if err := util.ValidateNodeName("legacy-aws"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The darknode is only migrated once.
+	created, err = CreateMetadata("legacy-aws")
+	if err != nil || created {
+		t.Fatalf("darknode is migrated again, created = %v, err = %v", created, err)
+	}
+}
+
+func TestLegacyMetadataOnFirstRead(t *testing.T) {
+	fake := setupNode(t, "legacy-aws", "")
+	fake.Values = util.Outputs{"provider": "aws", "ip": "3.250.1.2"}
+	copyFixture(t, "legacy-aws", "legacy-aws")
+
+	oldVersion := darknodeVersion
+	darknodeVersion = func(name string) string { return "0.2.9" }
+	defer func() { darknodeVersion = oldVersion }()
+
+	// Commands work with darknodes deployed by an older version of the CLI
+	// without running `darknode migrate` first.
+	if err := util.ValidateNodeName("legacy-aws"); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := util.ReadMetadata("legacy-aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Provider != NameAws || meta.IP != "3.250.1.2" || meta.Region != "eu-west-1" || meta.Instance != "t3.micro" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if ip, err := util.IP("legacy-aws"); err != nil || ip != "3.250.1.2" {
+		t.Fatalf("unexpected ip %v, err = %v", ip, err)
+	}
+
+	// Outputs are only read once.
+	if len(fake.Commands) != 1 || fake.Commands[0] != "output" {
+		t.Fatalf("unexpected commands %v", fake.Commands)
+	}
+
+	// The error is returned if the metadata cannot be created.
+	copyFixture(t, "legacy-aws", "broken")
+	fake.Values = util.Outputs{}
+	if err := util.ValidateNodeName("broken"); err == nil {
+		t.Fatal("expected an error for a darknode without the provider output")
+	}
+}
+
+func TestLegacyTags(t *testing.T) {
+	tests := []struct {
+		tags string
+		want []string
+	}{
+		{"", []string{}},
+		{"mainnet,eu", []string{"eu", "mainnet"}},
+		{" eu team , mainnet,eu-team", []string{"eu-team", "mainnet"}},
+		{"a|b,!c,***", []string{"a-b", "c"}},
+	}
+	for _, test := range tests {
+		if got := legacyTags("test", test.tags); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("legacyTags(%q) = %v, want %v", test.tags, got, test.want)
+		}
+	}
+}
+
+func TestSyncMetadataSSHPort(t *testing.T) {
+	fake := setupNode(t, "test", "")
+	fake.Values = util.Outputs{"provider": "docker", "ip": "127.0.0.1", "ssh_port": float64(32768)}
+	if err := util.WriteMetadata(util.Metadata{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if util.SSHPort("test") != "22" {
+		t.Fatalf("unexpected default ssh port %v", util.SSHPort("test"))
+	}
+	if err := SyncMetadata("test", nil); err != nil {
+		t.Fatal(err)
+	}
+	if ip, err := util.IP("test"); err != nil || ip != "127.0.0.1" || util.SSHPort("test") != "32768" {
+		t.Fatalf("unexpected address %v:%v, err = %v", ip, util.SSHPort("test"), err)
+	}
+}
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+func TestTemplatePermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		permissions map[string][]string
+		all         []string
+		expected    []string
+	}{
+		{
+			NameAws, awsTemplate, awsResourceActions, AwsActions(),
+			[]string{
+				"ec2:AllocateAddress", "ec2:AssociateAddress", "ec2:ReleaseAddress",
+				"ec2:CreateVpc", "ec2:CreateSubnet", "ec2:CreateInternetGateway", "ec2:CreateRouteTable",
+				"ec2:RevokeSecurityGroupIngress", "ec2:RunInstances",
+			},
+		},
+		{
+			NameGcp, gcpTemplate, gcpResourcePermissions, GcpPermissions(),
+			[]string{
+				"compute.addresses.create", "compute.subnetworks.create", "compute.instances.setMetadata",
+				"compute.firewalls.update", "compute.instances.create",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Every resource in the template needs to be known, so new
+			// resources cannot be added without their permissions.
+			for _, resourceType := range resourceTypes(test.template) {
+				if _, ok := test.permissions[resourceType]; !ok {
+					t.Errorf("no permissions for the %v resources in the template", resourceType)
+				}
+			}
+			for _, permission := range test.expected {
+				if !util.StringInSlice(permission, test.all) {
+					t.Errorf("%v is not checked", permission)
+				}
+			}
+		})
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"regexp"
 
 	"github.com/fatih/color"
 	"github.com/renproject/darknode-cli/darknode"
@@ -24,12 +24,27 @@ var (
 
 	// ErrRegionNotAvailable is returned when the selected region is not available to user account.
 	ErrRegionNotAvailable = errors.New("selected region is not available")
+
+	// ErrSpotNotSupported is returned when user asks for a spot instance from a provider which does not offer them.
+	ErrSpotNotSupported = errors.New("spot instance is only supported on AWS and Google Cloud Platform")
+
+	// ErrSpotOnMainnet is returned when user tries to deploy a mainnet darknode on a spot instance.
+	ErrSpotOnMainnet = errors.New("spot instance can only be used for devnet, testnet and chaosnet darknodes")
+
+	// ErrStaticIPNotSupported is returned when user asks for a static IP from a provider which cannot allocate one.
+	ErrStaticIPNotSupported = errors.New("static IP is only supported on AWS, Digital Ocean and Google Cloud Platform")
+
+	// ErrMFAUnattended is returned when credentials which need an MFA code are used by a command which runs unattended.
+	ErrMFAUnattended = errors.New("the aws credentials need an MFA code, which cannot be entered when running unattended, please use a profile or role without MFA")
 )
 
 var (
-	NameAws = "aws"
-	NameDo  = "do"
-	NameGcp = "gcp"
+	NameAws     = "aws"
+	NameDo      = "do"
+	NameGcp     = "gcp"
+	NameHetzner = "hetzner"
+	NameSsh     = "ssh"
+	NameDocker  = "docker"
 )
 
 var darknodeService = `[Unit]
@@ -40,9 +55,11 @@ AssertPathExists=$HOME/.darknode
 WorkingDirectory=$HOME/.darknode
 ExecStart=$HOME/.darknode/bin/darknode --config $HOME/.darknode/config.json
 Restart=on-failure
-PrivateTmp=true
 NoNewPrivileges=true
 
+# PrivateTmp is not set as it needs unprivileged user namespaces in user units,
+# which are restricted on Ubuntu 24.04.
+
 # Specifies which signal to use when killing a service. Defaults to SIGTERM.
 # SIGHUP gives parity time to exit cleanly before SIGKILL (default 90s)
 KillSignal=SIGHUP
@@ -50,9 +67,64 @@ KillSignal=SIGHUP
 [Install]
 WantedBy=default.target`
 
+// Status represents the deployment status of a darknode instance.
+type Status int
+
+const (
+	StatusNotDeployed Status = iota // Either never deployed or failed to be deployed.
+	StatusDeployed
+)
+
+// String implements the `fmt.Stringer` interface.
+func (status Status) String() string {
+	switch status {
+	case StatusDeployed:
+		return "deployed"
+	default:
+		return "not deployed"
+	}
+}
+
+// Provider is a cloud provider which manages the full lifecycle of darknode
+// instances.
 type Provider interface {
+	// Name returns the name of the provider.
 	Name() string
-	Deploy(ctx *cli.Context) error
+
+	// Placements validates the instance type and returns the regions for
+	// deploying the given number of darknodes. Random regions are selected if
+	// none are given. The account needs to have room for all the darknodes.
+	Placements(regions []string, instance string, count int) ([]string, error)
+
+	// Deploy a new darknode to the node directory initialised by initNode.
+	Deploy(d Deployment) error
+
+	// Destroy tears down all resources allocated for the darknode.
+	Destroy(name string) error
+
+	// Resize changes the instance type of the darknode.
+	Resize(name, instance string) error
+
+	// Status returns the deployment status of the darknode.
+	Status(name string) (Status, error)
+
+	// AllowSSH restricts SSH access to the darknode to the given CIDR ranges,
+	// both in the cloud firewall and ufw on the host.
+	AllowSSH(name string, cidrs []string) error
+
+	// Recover redeploys the resources of the darknode which no longer exist,
+	// e.g. a reclaimed spot instance. It returns whether anything has been
+	// redeployed.
+	Recover(name string) (bool, error)
+
+	// Instance returns the region and instance type of the darknode.
+	Instance(name string) (region string, instance string, err error)
+
+	// Regions returns all regions the provider can deploy darknodes to.
+	Regions() ([]string, error)
+
+	// InstanceTypes returns all instance types available in the given region.
+	InstanceTypes(region string) ([]InstanceType, error)
 }
 
 func ParseProvider(ctx *cli.Context) (Provider, error) {
@@ -68,23 +140,111 @@ func ParseProvider(ctx *cli.Context) (Provider, error) {
 		return NewGcp(ctx)
 	}
 
+	if ctx.Bool(NameHetzner) {
+		return NewHetzner(ctx)
+	}
+
+	if ctx.Bool(NameDocker) {
+		return NewDocker(ctx)
+	}
+
+	if ctx.String("ssh-host") != "" {
+		return NewSsh(ctx)
+	}
+
 	return nil, ErrUnknownProvider
 }
 
-// Provider returns the provider of a darknode instance.
+// ParseNodeProvider returns the Provider which manages the darknode with given
+// name, using the credentials given in the cli context. It should be used when
+// terraform needs to talk to the provider.
+func ParseNodeProvider(ctx *cli.Context, name string) (Provider, error) {
+	p, err := GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(ctx, p)
+}
+
+// NewProvider returns the Provider with given name, using the credentials given
+// in the cli context.
+func NewProvider(ctx *cli.Context, name string) (Provider, error) {
+	switch name {
+	case NameAws:
+		return NewAws(ctx)
+	case NameDo:
+		return NewDo(ctx)
+	case NameGcp:
+		return NewGcp(ctx)
+	case NameHetzner:
+		return NewHetzner(ctx)
+	case NameSsh:
+		return providerSsh{}, nil
+	case NameDocker:
+		return NewDocker(ctx)
+	default:
+		return nil, ErrUnknownProvider
+	}
+}
+
+// NodeProvider returns the Provider which manages the darknode with given name.
+// The Provider has no credentials, so it can only be used for operations which
+// don't talk to the provider.
+func NodeProvider(name string) (Provider, error) {
+	p, err := GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	return providerByName(p)
+}
+
+// providerByName returns the Provider without credentials of the given provider
+// name, e.g. "aws".
+func providerByName(name string) (Provider, error) {
+	switch name {
+	case NameAws:
+		return providerAws{}, nil
+	case NameDo:
+		return providerDo{}, nil
+	case NameGcp:
+		return providerGcp{}, nil
+	case NameHetzner:
+		return providerHetzner{}, nil
+	case NameSsh:
+		return providerSsh{}, nil
+	case NameDocker:
+		return providerDocker{}, nil
+	default:
+		return nil, ErrUnknownProvider
+	}
+}
+
+// GetProvider returns the provider name of a darknode instance. It's read from
+// the metadata of the darknode, or the terraform outputs if the darknode is
+// still being deployed.
 func GetProvider(name string) (string, error) {
 	if name == "" {
 		return "", util.ErrEmptyName
 	}
+	if meta, err := util.ReadMetadata(name); err == nil && meta.Provider != "" {
+		return meta.Provider, nil
+	}
 
-	cmd := fmt.Sprintf("cd %v && terraform output provider", util.NodePath(name))
-	provider, err := util.CommandOutput(cmd)
-	return strings.TrimSpace(provider), err
+	outputs, err := util.NewTerraform(name, nil).Outputs()
+	if err != nil {
+		return "", err
+	}
+	return outputs.String("provider"), nil
 }
 
-// initialise all files needed by deploying a new node
-func initNode(name, tags string, network darknode.Network, configFile string) error {
-	if err := initNodeDirectory(name, tags); err != nil {
+// initialise all files needed by deploying a new node. The provider is recorded
+// before anything is deployed, so the node can always be destroyed.
+func initNode(name, provider, tags string, network darknode.Network, configFile string) error {
+	parsedTags, err := util.ParseTags(tags)
+	if err != nil {
+		return err
+	}
+	if err := initNodeDirectory(name); err != nil {
 		return err
 	}
 	if err := util.GenerateSshKeyAndWriteToDir(name); err != nil {
@@ -121,10 +281,19 @@ func initNode(name, tags string, network darknode.Network, configFile string) er
 		return err
 	}
 	configPath := filepath.Join(util.NodePath(name), "config.json")
-	return ioutil.WriteFile(configPath, configData, 0600)
+	if err := ioutil.WriteFile(configPath, configData, 0600); err != nil {
+		return err
+	}
+
+	return util.WriteMetadata(util.Metadata{
+		Name:     name,
+		Network:  conf.Network,
+		Provider: provider,
+		Tags:     parsedTags,
+	})
 }
 
-func initNodeDirectory(name, tags string) error {
+func initNodeDirectory(name string) error {
 	if name == "" {
 		return util.ErrEmptyName
 	}
@@ -136,33 +305,183 @@ func initNodeDirectory(name, tags string) error {
 	}
 
 	// Make a directory for the new node
-	if err := os.Mkdir(path, 0700); err != nil {
+	return os.Mkdir(path, 0700)
+}
+
+// runTerraform initialises terraform in the node directory and applies the
+// config. Credentials of the provider are passed to terraform as environment
+// variables, so that they are never written to the node directory.
+func runTerraform(name string, env []string, quiet bool) error {
+	tf := util.NewTerraform(name, env)
+	if quiet {
+		tf = tf.Quiet()
+	}
+	if err := tf.Init(); err != nil {
 		return err
 	}
 
-	// Create the `tags.out` file if not exist.
-	tagsPath := filepath.Join(path, "tags.out")
-	if _, err := os.Stat(tagsPath); err != nil {
-		return ioutil.WriteFile(tagsPath, []byte(strings.TrimSpace(tags)), 0600)
-	}
+	fmt.Printf("Deploying darknode [%v] ...\n", name)
+	return tf.Apply()
+}
 
-	return nil
+// destroyTerraform tears down all resources managed by terraform in the node
+// directory.
+func destroyTerraform(name string, env []string) error {
+	return util.NewTerraform(name, env).Destroy()
 }
 
-func runTerraform(name string) error {
+// HasState returns whether terraform may be managing any resources of the
+// darknode. A darknode whose deployment failed before terraform created anything
+// has no state, and its directory can be removed without running terraform.
+func HasState(name string) bool {
 	path := util.NodePath(name)
-	init := fmt.Sprintf("cd %v && terraform init", path)
-	if err := util.Run("bash", "-c", init); err != nil {
+	tf, err := ioutil.ReadFile(filepath.Join(path, "main.tf"))
+	if err != nil {
+		return false
+	}
+	if hasBackend(tf) {
+		// The remote state can only be checked with the credentials, assume it
+		// has resources once terraform has been initialised.
+		_, err := os.Stat(filepath.Join(path, ".terraform"))
+		return err == nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(path, "terraform.tfstate"))
+	if err != nil {
+		return false
+	}
+	var state struct {
+		Resources []json.RawMessage `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return true
+	}
+	return len(state.Resources) > 0
+}
+
+// resizeTerraform replaces the instance type in the terraform config using the
+// given regex and applies the changes. The config will be reverted if terraform
+// fails to apply the changes.
+func resizeTerraform(name string, env []string, regex, replacement string) error {
+	reg, err := regexp.Compile(regex)
+	if err != nil {
+		return err
+	}
+
+	// Update the main.tf file.
+	path := filepath.Join(util.NodePath(name), "main.tf")
+	tf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	newTf := reg.ReplaceAll(tf, []byte(replacement))
+	if err := ioutil.WriteFile(path, newTf, 0600); err != nil {
 		return err
 	}
 
-	fmt.Println("Deploying darknode ... ")
-	apply := fmt.Sprintf("cd %v && terraform apply -auto-approve -no-color", path)
-	return util.Run("bash", "-c", apply)
+	// Apply the changes using terraform
+	color.Green("Resizing dark nodes ...")
+	if err := util.NewTerraform(name, env).Apply(); err != nil {
+		// revert the `main.tf` file if fail to resize the instance
+		if revertErr := ioutil.WriteFile(path, tf, 0600); revertErr != nil {
+			fmt.Println("fail to revert the change to `main.tf` file")
+		}
+		if errors.Is(err, util.ErrInvalidInstanceType) {
+			return fmt.Errorf("%w: %v", ErrInstanceTypeNotAvailable, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// instanceTerraform returns the region and instance type of the darknode from
+// its metadata. They are found in the terraform config using the given regexes
+// if the metadata doesn't have them, e.g. when migrating a darknode deployed by
+// an older version of the CLI.
+func instanceTerraform(name, regionRegex, instanceRegex string) (string, string, error) {
+	if meta, err := util.ReadMetadata(name); err == nil && meta.Region != "" && meta.Instance != "" {
+		return meta.Region, meta.Instance, nil
+	}
+	path := filepath.Join(util.NodePath(name), "main.tf")
+	tf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	values := make([]string, 2)
+	for i, regex := range []string{regionRegex, instanceRegex} {
+		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return "", "", err
+		}
+		match := reg.FindSubmatch(tf)
+		if len(match) < 2 {
+			return "", "", fmt.Errorf("cannot find the region and instance type of [%v] in main.tf", name)
+		}
+		values[i] = string(match[1])
+	}
+	return values[0], values[1], nil
+}
+
+// recoverTerraform redeploys the instance of the darknode if it no longer
+// exists, which is checked by refreshing the terraform state against the
+// provider. Nothing is applied unless the plan creates the instance of the
+// given resource type without destroying any other resource, so drift of a
+// healthy darknode never gets it replaced. The darknode keeps its keystore as
+// the config file is uploaded from the node directory.
+func recoverTerraform(name string, env []string, instanceType string) (bool, error) {
+	tf := util.NewTerraform(name, env)
+	changes, err := tf.Plan()
+	if err != nil {
+		return false, err
+	}
+	created := false
+	for _, change := range changes {
+		if change.Type == instanceType && change.Creates() && !change.Deletes() {
+			created = true
+			continue
+		}
+		// The provisioners run again for the new instance.
+		if change.Deletes() && change.Type != "null_resource" {
+			return false, fmt.Errorf("recovering [%v] would destroy %v, please check the changes with `terraform plan` in the node directory", name, change.Address)
+		}
+	}
+	if !created {
+		return false, nil
+	}
+	if err := tf.Apply(); err != nil {
+		return true, err
+	}
+	if err := SyncMetadata(name, nil); err != nil {
+		return true, err
+	}
+	return true, waitForReady(name)
+}
+
+// parseSpot returns whether the darknode should be deployed on a spot instance.
+func parseSpot(ctx *cli.Context, network darknode.Network) (bool, error) {
+	if !ctx.Bool("spot") {
+		return false, nil
+	}
+	if network == darknode.Mainnet {
+		return false, ErrSpotOnMainnet
+	}
+	return true, nil
+}
+
+// statusTerraform checks the terraform outputs to tell whether the darknode has
+// been fully deployed.
+func statusTerraform(name string) (Status, error) {
+	if name == "" {
+		return StatusNotDeployed, util.ErrEmptyName
+	}
+	ip, err := util.IP(name)
+	if err != nil || ip == "" {
+		return StatusNotDeployed, nil
+	}
+	return StatusDeployed, nil
 }
 
-// outputURL writes success message and the URL for registering the node to the terminal.
-func outputURL(name string) error {
+// OutputURL writes success message and the URL for registering the node to the terminal.
+func OutputURL(name string) error {
 	url, err := util.RegisterUrl(name)
 	if err != nil {
 		return err
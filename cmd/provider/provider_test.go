@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/renproject/darknode-cli/darknode"
+	"github.com/renproject/darknode-cli/internal/terraformtest"
+	"github.com/renproject/darknode-cli/util"
+)
+
+// setupNode points the CLI directory to a temporary directory with a node
+// directory containing the given main.tf, and replaces terraform with a fake.
+func setupNode(t *testing.T, name, mainTf string) *terraformtest.Fake {
+	dir, err := ioutil.TempDir("", "darknode-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDirectory, oldTerraform := util.Directory, util.NewTerraform
+	fake := terraformtest.NewFake(util.Outputs{})
+	util.Directory, util.NewTerraform = dir, fake.New
+	t.Cleanup(func() {
+		util.Directory, util.NewTerraform = oldDirectory, oldTerraform
+		os.RemoveAll(dir)
+	})
+
+	if err := os.MkdirAll(util.NodePath(name), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(util.NodePath(name), "main.tf"), []byte(mainTf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fake
+}
+
+func TestResizeTerraform(t *testing.T) {
+	const mainTf = `resource "aws_instance" "darknode" {
+  instance_type = "t3.micro"
+}
+`
+	const resized = `resource "aws_instance" "darknode" {
+  instance_type = "t3.small"
+}
+`
+	tests := []struct {
+		name     string
+		applyErr error
+		want     error
+		tf       string
+	}{
+		{"success", nil, nil, resized},
+		{"invalid instance type", &util.TerraformError{Name: "test", Command: "apply", Err: util.ErrInvalidInstanceType}, ErrInstanceTypeNotAvailable, mainTf},
+		{"auth", &util.TerraformError{Name: "test", Command: "apply", Err: util.ErrAuthFailed}, util.ErrAuthFailed, mainTf},
+		{"quota", &util.TerraformError{Name: "test", Command: "apply", Err: util.ErrQuotaExceeded}, util.ErrQuotaExceeded, mainTf},
+		{"state lock", &util.TerraformError{Name: "test", Command: "apply", Err: util.ErrStateLocked}, util.ErrStateLocked, mainTf},
+		{"other", &util.TerraformError{Name: "test", Command: "apply", Err: util.ErrApplyFailed}, util.ErrApplyFailed, mainTf},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := setupNode(t, "test", mainTf)
+			fake.Errs["apply"] = test.applyErr
+
+			err := resizeTerraform("test", nil, `instance_type\s*=\s*".*"`, `instance_type = "t3.small"`)
+			if test.want == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, err)
+			}
+			if test.want != ErrInstanceTypeNotAvailable && errors.Is(err, ErrInstanceTypeNotAvailable) {
+				t.Fatalf("%v is reported as an unavailable instance type", err)
+			}
+
+			tf, err := ioutil.ReadFile(filepath.Join(util.NodePath("test"), "main.tf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(tf) != test.tf {
+				t.Fatalf("unexpected main.tf:\n%v", string(tf))
+			}
+		})
+	}
+}
+
+func TestInitNode(t *testing.T) {
+	setupNode(t, "test", "")
+	if err := os.RemoveAll(util.NodePath("test")); err != nil {
+		t.Fatal(err)
+	}
+	if err := initNode("test", NameAws, "mainnet", darknode.Testnet, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider is known before terraform has any outputs.
+	name, err := GetProvider("test")
+	if err != nil || name != NameAws {
+		t.Fatalf("expected %v, got %v, %v", NameAws, name, err)
+	}
+	if HasState("test") {
+		t.Fatal("darknode which has not been deployed has a state")
+	}
+}
+
+func TestHasState(t *testing.T) {
+	tests := []struct {
+		name     string
+		mainTf   string
+		files    map[string]string
+		hasState bool
+	}{
+		{"no config", "", nil, false},
+		{"init failed", "provider \"aws\" {}", nil, false},
+		{"apply failed early", "provider \"aws\" {}", map[string]string{"terraform.tfstate": `{"version": 4, "resources": []}`}, false},
+		{"deployed", "provider \"aws\" {}", map[string]string{"terraform.tfstate": `{"version": 4, "resources": [{"type": "aws_instance"}]}`}, true},
+		{"remote state not initialised", "terraform {\n  backend \"s3\" {}\n}", nil, false},
+		{"remote state", "terraform {\n  backend \"s3\" {}\n}", map[string]string{".terraform/terraform.tfstate": "{}"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupNode(t, "test", test.mainTf)
+			if test.mainTf == "" {
+				os.Remove(filepath.Join(util.NodePath("test"), "main.tf"))
+			}
+			for file, content := range test.files {
+				path := filepath.Join(util.NodePath("test"), file)
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if HasState("test") != test.hasState {
+				t.Fatalf("expected %v", test.hasState)
+			}
+		})
+	}
+}
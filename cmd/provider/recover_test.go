@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+func TestRecoverTerraform(t *testing.T) {
+	oldTimeout := ReadyTimeout
+	ReadyTimeout = 0
+	defer func() { ReadyTimeout = oldTimeout }()
+
+	instance := util.ResourceChange{Address: "aws_instance.darknode", Type: "aws_instance", Actions: []string{"create"}}
+	replaced := util.ResourceChange{Address: "aws_instance.darknode", Type: "aws_instance", Actions: []string{"delete", "create"}}
+	ufw := util.ResourceChange{Address: "null_resource.ufw", Type: "null_resource", Actions: []string{"delete", "create"}}
+	drift := util.ResourceChange{Address: "aws_security_group.darknode", Type: "aws_security_group", Actions: []string{"update"}}
+
+	tests := []struct {
+		name      string
+		changes   []util.ResourceChange
+		recovered bool
+		err       string
+	}{
+		{"no changes", nil, false, ""},
+		{"drift", []util.ResourceChange{drift}, false, ""},
+		{"reclaimed", []util.ResourceChange{instance, ufw, drift}, true, ErrNotReady.Error()},
+		{"new image", []util.ResourceChange{replaced, ufw}, false, "would destroy aws_instance.darknode"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := setupNode(t, "test", "")
+			fake.Changes = test.changes
+			if err := util.WriteMetadata(util.Metadata{Name: "test", Provider: NameAws}); err != nil {
+				t.Fatal(err)
+			}
+			recovered, err := recoverTerraform("test", nil, "aws_instance")
+			if recovered != test.recovered {
+				t.Fatalf("expected recovered = %v, got %v", test.recovered, recovered)
+			}
+			if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+			applied := strings.Contains(strings.Join(fake.Commands, ","), "apply")
+			if applied != test.recovered {
+				t.Fatalf("expected applied = %v, commands = %v", test.recovered, fake.Commands)
+			}
+		})
+	}
+
+	// Errors of the plan are returned without applying.
+	fake := setupNode(t, "test", "")
+	fake.Errs["plan"] = &util.TerraformError{Name: "test", Command: "plan", Err: util.ErrAuthFailed}
+	if _, err := recoverTerraform("test", nil, "aws_instance"); !errors.Is(err, util.ErrAuthFailed) {
+		t.Fatalf("expected %v, got %v", util.ErrAuthFailed, err)
+	}
+}
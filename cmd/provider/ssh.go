@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	// ErrNotSupported is returned when the operation is not supported by the provider.
+	ErrNotSupported = errors.New("operation is not supported by the provider")
+
+	// ErrManyNodesOnServer is returned when user tries to deploy more than one darknode to an existing server.
+	ErrManyNodesOnServer = errors.New("only one darknode can be deployed to an existing server")
+)
+
+// providerSsh deploys darknodes to existing servers over SSH without
+// provisioning any cloud resources.
+type providerSsh struct {
+	host    string
+	user    string
+	keyPath string
+}
+
+func NewSsh(ctx *cli.Context) (Provider, error) {
+	host := strings.TrimSpace(ctx.String("ssh-host"))
+	user := strings.TrimSpace(ctx.String("ssh-user"))
+	keyPath, err := expandPath(ctx.String("ssh-key"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(keyPath); err != nil {
+		return nil, fmt.Errorf("cannot find the ssh key, err = %v", err)
+	}
+
+	return providerSsh{
+		host:    host,
+		user:    user,
+		keyPath: keyPath,
+	}, nil
+}
+
+func (p providerSsh) Name() string {
+	return NameSsh
+}
+
+// env returns no environment variables as no cloud credentials are needed.
+func (p providerSsh) env() []string {
+	return nil
+}
+
+// Placements checks we can login to the server. Only one darknode can be
+// deployed to a server.
+func (p providerSsh) Placements(regions []string, instance string, count int) ([]string, error) {
+	if count > 1 {
+		return nil, ErrManyNodesOnServer
+	}
+	if _, _, err := p.validateHost(); err != nil {
+		return nil, err
+	}
+	return []string{""}, nil
+}
+
+func (p providerSsh) Deploy(d Deployment) error {
+	ip, hostKey, err := p.validateHost()
+	if err != nil {
+		return err
+	}
+	if err := p.tfConfig(d.Name, ip, hostKey, d.Version); err != nil {
+		return err
+	}
+	if err := runTerraform(d.Name, p.env(), d.Quiet); err != nil {
+		return err
+	}
+	if err := deployed(d); err != nil {
+		return err
+	}
+	return waitForReady(d.Name)
+}
+
+// Destroy stops the darknode and removes the darknode user from the server. The
+// server itself will not be affected.
+func (p providerSsh) Destroy(name string) error {
+	return destroyTerraform(name, p.env())
+}
+
+func (p providerSsh) Resize(name, instance string) error {
+	return ErrNotSupported
+}
+
+func (p providerSsh) Status(name string) (Status, error) {
+	return statusTerraform(name)
+}
+
+func (p providerSsh) AllowSSH(name string, cidrs []string) error {
+	return allowSSHTerraform(name, p.env(), cidrs)
+}
+
+func (p providerSsh) Recover(name string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (p providerSsh) Instance(name string) (string, string, error) {
+	return "", "", ErrNotSupported
+}
+
+func (p providerSsh) Regions() ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (p providerSsh) InstanceTypes(region string) ([]InstanceType, error) {
+	return nil, ErrNotSupported
+}
+
+// knownHostsPath is the known_hosts file which the host keys of existing servers
+// are checked against.
+var knownHostsPath = filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+
+// validateHost resolves the IP address of the host and checks we can login to
+// the server with the given user and ssh key. The host key of the server needs
+// to be in the known_hosts file, it's returned so terraform can check it too.
+func (p providerSsh) validateHost() (string, string, error) {
+	if p.host == "" {
+		return "", "", errors.New("ssh host cannot be empty")
+	}
+	if p.user == "" {
+		return "", "", errors.New("ssh user cannot be empty")
+	}
+	ip, err := resolveIP(p.host)
+	if err != nil {
+		return "", "", err
+	}
+
+	key, err := ioutil.ReadFile(p.keyPath)
+	if err != nil {
+		return "", "", err
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			return "", "", errors.New("ssh keys protected by a passphrase are not supported")
+		}
+		return "", "", err
+	}
+	var hostKey string
+	config := ssh.ClientConfig{
+		User: p.user,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+		},
+		Timeout: 10 * time.Second,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+			return checkKnownHost(net.JoinHostPort(p.host, "22"), remote, key)
+		},
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(ip, "22"), &config)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot connect to [%v] as [%v], err = %v", p.host, p.user, err)
+	}
+	defer client.Close()
+
+	// Make sure the user has root access to provision the server.
+	session, err := client.NewSession()
+	if err != nil {
+		return "", "", err
+	}
+	defer session.Close()
+	if err := session.Run("sudo -n true"); err != nil {
+		return "", "", fmt.Errorf("user [%v] needs passwordless sudo to provision the darknode", p.user)
+	}
+	return ip, hostKey, nil
+}
+
+// checkKnownHost checks the host key of the server against the known_hosts
+// file. Unknown servers are rejected with the fingerprint of their key, so the
+// user can verify it before trusting the server.
+func checkKnownHost(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	callback, err := knownhosts.New(knownHostsPath)
+	if err == nil {
+		err = callback(hostname, remote, key)
+	}
+	if err == nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
+		return fmt.Errorf("host key of [%v] does not match the one in %v, the server may have been replaced or the connection is intercepted. Its fingerprint is %v", hostname, knownHostsPath, fingerprint)
+	}
+	return fmt.Errorf("[%v] is not a known host, the fingerprint of its host key is %v. Please verify it and add the host to %v, e.g. by connecting with ssh once", hostname, fingerprint, knownHostsPath)
+}
+
+// resolveIP returns the IP address of the host, IPv4 addresses are preferred.
+func resolveIP(host string) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String(), nil
+		}
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("cannot resolve the IP address of [%v]", host)
+	}
+	return ips[0].String(), nil
+}
+
+// expandPath expands the `~` prefix and returns the absolute path.
+func expandPath(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		path = filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,148 @@
+package provider
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+type sshTerraform struct {
+	Name          string
+	Host          string
+	User          string
+	KeyPath       string
+	ConfigPath    string
+	ServicePath   string
+	BootstrapPath string
+	HostKey       string
+	Firewall      string
+}
+
+// tfConfig generates the terraform config file for deploying to an existing
+// server. No cloud resources will be created, terraform is only used for running
+// the provisioners and recording the outputs.
+func (p providerSsh) tfConfig(name, ip, hostKey, latestVersion string) error {
+	if err := writeBootstrapFiles(name, latestVersion); err != nil {
+		return err
+	}
+
+	tf := sshTerraform{
+		Name:          name,
+		Host:          ip,
+		User:          p.user,
+		KeyPath:       p.keyPath,
+		ConfigPath:    fmt.Sprintf("~/.darknode/darknodes/%v/config.json", name),
+		ServicePath:   fmt.Sprintf("~/.darknode/darknodes/%v/darknode.service", name),
+		BootstrapPath: fmt.Sprintf("~/.darknode/darknodes/%v/bootstrap.sh", name),
+		HostKey:       hostKey,
+		Firewall:      sshVariable + ufwResource("null_resource.darknode.id", "null_resource.darknode.triggers.host", "null_resource.darknode.triggers.user", p.keyPath),
+	}
+
+	t, err := template.New(NameSsh).Parse(sshTemplate)
+	if err != nil {
+		return err
+	}
+	return writeTerraformConfig(name, util.NodePath(name), t, tf)
+}
+
+var sshTemplate = `
+resource "null_resource" "darknode" {
+
+  triggers = {
+    host = "{{.Host}}"
+    user = "{{.User}}"
+  }
+
+  provisioner "file" {
+
+    source      = "{{.ConfigPath}}"
+    destination = "darknode-config.json"
+
+    connection {
+      host        = self.triggers.host
+      type        = "ssh"
+      user        = self.triggers.user
+      private_key = file("{{.KeyPath}}")
+      host_key    = "{{.HostKey}}"
+    }
+  }
+
+  provisioner "file" {
+
+    source      = "{{.ServicePath}}"
+    destination = "darknode.service"
+
+    connection {
+      host        = self.triggers.host
+      type        = "ssh"
+      user        = self.triggers.user
+      private_key = file("{{.KeyPath}}")
+      host_key    = "{{.HostKey}}"
+    }
+  }
+
+  provisioner "file" {
+
+    source      = "{{.BootstrapPath}}"
+    destination = "darknode-bootstrap.sh"
+
+    connection {
+      host        = self.triggers.host
+      type        = "ssh"
+      user        = self.triggers.user
+      private_key = file("{{.KeyPath}}")
+      host_key    = "{{.HostKey}}"
+    }
+  }
+
+  // Run the same bootstrap script which cloud-init runs on cloud providers.
+  provisioner "remote-exec" {
+
+	inline = [
+      "set -e",
+      "sudo install -d -m 700 /var/lib/darknode",
+      "sudo mv $HOME/darknode-config.json /var/lib/darknode/config.json",
+      "sudo mv $HOME/darknode.service /var/lib/darknode/darknode.service",
+      "sudo mv $HOME/darknode-bootstrap.sh /var/lib/darknode/bootstrap.sh",
+      "sudo bash /var/lib/darknode/bootstrap.sh /var/lib/darknode existing",
+	]
+
+    connection {
+      host        = self.triggers.host
+      type        = "ssh"
+      user        = self.triggers.user
+      private_key = file("{{.KeyPath}}")
+      host_key    = "{{.HostKey}}"
+    }
+  }
+
+  provisioner "remote-exec" {
+    when = destroy
+
+	inline = [
+      "set -x",
+      "sudo loginctl disable-linger darknode",
+      "sudo pkill -u darknode || true",
+      "sudo deluser --remove-home darknode",
+      "if command -v ufw > /dev/null; then sudo ufw delete allow 18514/tcp || true; sudo ufw delete allow 18515/tcp || true; fi",
+	]
+
+    connection {
+      host        = self.triggers.host
+      type        = "ssh"
+      user        = self.triggers.user
+      private_key = file("{{.KeyPath}}")
+      host_key    = "{{.HostKey}}"
+    }
+  }
+}
+
+{{.Firewall}}
+output "provider" {
+  value = "ssh"
+}
+
+output "ip" {
+  value = null_resource.darknode.triggers.host
+}`
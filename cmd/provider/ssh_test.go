@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/renproject/darknode-cli/util"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestCheckKnownHost(t *testing.T) {
+	known, other := newHostKey(t), newHostKey(t)
+	dir, err := ioutil.TempDir("", "darknode-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize("example.com:22")}, known)
+	if err := ioutil.WriteFile(path, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := knownHostsPath
+	knownHostsPath = path
+	defer func() { knownHostsPath = old }()
+
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+	if err := checkKnownHost("example.com:22", remote, known); err != nil {
+		t.Errorf("known host is rejected, err = %v", err)
+	}
+	err = checkKnownHost("example.com:22", remote, other)
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("changed host key is not rejected, err = %v", err)
+	}
+	err = checkKnownHost("unknown.com:22", remote, known)
+	if err == nil || !strings.Contains(err.Error(), ssh.FingerprintSHA256(known)) {
+		t.Errorf("unknown host is not rejected with its fingerprint, err = %v", err)
+	}
+
+	knownHostsPath = filepath.Join(dir, "missing")
+	err = checkKnownHost("example.com:22", remote, known)
+	if err == nil || !strings.Contains(err.Error(), "not a known host") {
+		t.Errorf("host is accepted without a known_hosts file, err = %v", err)
+	}
+}
+
+func TestSshTemplate(t *testing.T) {
+	setupNode(t, "test", "")
+	stubRelease(t)
+	hostKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(newHostKey(t))))
+	p := providerSsh{host: "example.com", user: "ubuntu", keyPath: "/tmp/id_ed25519"}
+	if err := p.tfConfig("test", "192.0.2.1", hostKey, "0.4.0"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(util.NodePath("test"), "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf := string(data)
+
+	if strings.Count(tf, `host_key    = "`+hostKey+`"`) != 5 {
+		t.Error("not every provisioner of the darknode pins the host key")
+	}
+	if strings.Contains(tf, `destination = "$HOME`) {
+		t.Error("file provisioners upload to $HOME which is not expanded")
+	}
+	if !strings.Contains(tf, "bootstrap.sh /var/lib/darknode existing") {
+		t.Error("the bootstrap script does not run in the existing mode")
+	}
+	if strings.Contains(tf, "ufw --force enable") {
+		t.Error("main.tf enables the firewall of the existing server")
+	}
+}
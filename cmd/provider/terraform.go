@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/template"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+// providerSource is a terraform provider used by the generated configs.
+type providerSource struct {
+	Name    string
+	Source  string
+	Version string
+}
+
+var nullProvider = providerSource{"null", "hashicorp/null", "~> 3.0"}
+
+// terraformProviders are the terraform providers used by the config of each
+// provider. Major versions are pinned so that a new release of a provider never
+// breaks existing darknodes.
+var terraformProviders = map[string][]providerSource{
+	NameAws:     {{"aws", "hashicorp/aws", "~> 5.0"}, nullProvider},
+	NameDo:      {{"digitalocean", "digitalocean/digitalocean", "~> 2.0"}, nullProvider},
+	NameGcp:     {{"google", "hashicorp/google", "~> 6.0"}, nullProvider},
+	NameHetzner: {{"hcloud", "hetznercloud/hcloud", "~> 1.45"}, nullProvider},
+	NameSsh:     {nullProvider},
+	NameDocker:  {{"docker", "kreuzwerker/docker", "~> 3.0"}, nullProvider},
+}
+
+// regexResourceType matches the type of the resources and data sources in a
+// terraform config.
+var regexResourceType = regexp.MustCompile(`(?m)^(?:resource|data)\s+"(\w+)"`)
+
+// resourceTypes returns the types of all the resources and data sources in the
+// terraform config or template, including the ones which are only created with
+// some of the options.
+func resourceTypes(tf string) []string {
+	var types []string
+	for _, match := range regexResourceType.FindAllStringSubmatch(tf, -1) {
+		if !util.StringInSlice(match[1], types) {
+			types = append(types, match[1])
+		}
+	}
+	return types
+}
+
+// permissionsOf returns the sorted permissions needed by terraform to manage all
+// the resources in the terraform template, given the permissions needed by each
+// type of resource.
+func permissionsOf(tf string, permissions map[string][]string) []string {
+	set := map[string]bool{}
+	for _, resourceType := range resourceTypes(tf) {
+		for _, permission := range permissions[resourceType] {
+			set[permission] = true
+		}
+	}
+	sorted := make([]string, 0, len(set))
+	for permission := range set {
+		sorted = append(sorted, permission)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Regex for finding the provider in the outputs of the terraform config.
+var regexProviderOutput = regexp.MustCompile(`output "provider" {\s*value\s*=\s*"(\w+)"`)
+
+// requiredProviders returns the terraform block which tells terraform where to
+// find the providers used by the config of the given provider.
+func requiredProviders(provider string) (string, error) {
+	sources, ok := terraformProviders[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+	block := bytes.NewBufferString("terraform {\n  required_providers {\n")
+	for _, source := range sources {
+		fmt.Fprintf(block, "    %v = {\n      source  = %q\n      version = %q\n    }\n", source.Name, source.Source, source.Version)
+	}
+	block.WriteString("  }\n}\n")
+	return block.String(), nil
+}
+
+// writeTerraformConfig renders the terraform config of the darknode to the
+// `main.tf` file in the given directory. The template needs to be named after
+// the provider, so the required providers and the backend, if configured, can
+// be put in front of the config.
+func writeTerraformConfig(name, dir string, t *template.Template, data interface{}) error {
+	required, err := requiredProviders(t.Name())
+	if err != nil {
+		return err
+	}
+	backend, err := backendConfig(name)
+	if err != nil {
+		return err
+	}
+	tf := bytes.NewBufferString(required + backend)
+	if err := t.Execute(tf, data); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "main.tf"), tf.Bytes(), 0600)
+}
+
+// UpgradeTerraform upgrades the terraform config and state of a darknode
+// deployed with terraform 0.12, so they can be used by the terraform managed by
+// the CLI. It returns whether the darknode has been upgraded.
+func UpgradeTerraform(name string) (bool, error) {
+	path := filepath.Join(util.NodePath(name), "main.tf")
+	tf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Contains(tf, []byte("required_providers")) {
+		return false, nil
+	}
+	match := regexProviderOutput.FindSubmatch(tf)
+	if len(match) < 2 {
+		return false, fmt.Errorf("cannot find the provider of [%v] in main.tf", name)
+	}
+	provider := string(match[1])
+	required, err := requiredProviders(provider)
+	if err != nil {
+		return false, err
+	}
+	if err := ioutil.WriteFile(path, append([]byte(required), tf...), 0600); err != nil {
+		return false, err
+	}
+
+	// Terraform 0.12 recorded the providers without their namespace in the state.
+	terraform := util.NewTerraform(name, nil)
+	for _, source := range terraformProviders[provider] {
+		legacy := fmt.Sprintf("registry.terraform.io/-/%v", source.Name)
+		if err := terraform.Run("state", "replace-provider", "-auto-approve", "-no-color", legacy, source.Source); err != nil {
+			return false, err
+		}
+	}
+	if err := terraform.Init("-upgrade"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
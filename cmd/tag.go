@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/renproject/darknode-cli/util"
+	"github.com/urfave/cli"
+)
+
+// ErrEmptyTags is returned when no tags are given to the tag commands.
+var ErrEmptyTags = errors.New("please provide the comma separated tags")
+
+// addTags adds the given tags to the darknode.
+func addTags(ctx *cli.Context) error {
+	return updateTags(ctx, util.AddTags)
+}
+
+// removeTags removes the given tags from the darknode.
+func removeTags(ctx *cli.Context) error {
+	return updateTags(ctx, util.RemoveTags)
+}
+
+// setTags replaces the tags of the darknode with the given ones. An empty
+// string removes all the tags.
+func setTags(ctx *cli.Context) error {
+	name := ctx.Args().Get(0)
+	if err := util.ValidateNodeName(name); err != nil {
+		return err
+	}
+	if len(ctx.Args()) < 2 {
+		return ErrEmptyTags
+	}
+	tags, err := util.ParseTags(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	return util.UpdateMetadata(name, func(meta *util.Metadata) {
+		meta.Tags = tags
+	})
+}
+
+// updateTags changes the tags of the darknode with the tags given in the
+// arguments.
+func updateTags(ctx *cli.Context, update func(tags, changed []string) []string) error {
+	name := ctx.Args().Get(0)
+	if err := util.ValidateNodeName(name); err != nil {
+		return err
+	}
+	changed, err := util.ParseTags(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		return ErrEmptyTags
+	}
+	return util.UpdateMetadata(name, func(meta *util.Metadata) {
+		meta.Tags = update(meta.Tags, changed)
+	})
+}
+
+// listTags prints the tags of the darknode, or the tags of all darknodes
+// selected by the tags flag.
+func listTags(ctx *cli.Context) error {
+	name := ctx.Args().First()
+	if name != "" {
+		if err := util.ValidateNodeName(name); err != nil {
+			return err
+		}
+		meta, err := util.ReadMetadata(name)
+		if err != nil {
+			return err
+		}
+		for _, tag := range meta.Tags {
+			fmt.Println(tag)
+		}
+		return nil
+	}
+
+	nodes, err := util.GetNodesByTags(ctx.String("tags"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-20s | %-30s\n", "name", "tags")
+	for _, node := range nodes {
+		meta, err := util.ReadMetadata(node)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-20s | %-30s\n", node, strings.Join(meta.Tags, ","))
+	}
+	return nil
+}
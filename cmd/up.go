@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/cmd/provider"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/renproject/phi"
+	"github.com/urfave/cli"
+)
+
+var (
+	// ErrNameAndCount is returned when user gives both the names and the number
+	// of darknodes to deploy.
+	ErrNameAndCount = errors.New("cannot have both --name and --count")
+
+	// ErrEmptyNamePrefix is returned when user asks for many darknodes without
+	// a name prefix.
+	ErrEmptyNamePrefix = errors.New("please provide the --name-prefix of the darknodes")
+
+	// ErrDuplicateName is returned when the same name is given more than once.
+	ErrDuplicateName = errors.New("darknode names must be unique")
+)
+
+// deployResult is the outcome of deploying a single darknode.
+type deployResult struct {
+	deployment provider.Deployment
+	url        string
+	err        error
+}
+
+// up deploys a single darknode, or many darknodes in parallel when a list of
+// names or a count is given.
+func up(ctx *cli.Context) error {
+	p, err := provider.ParseProvider(ctx)
+	if err != nil {
+		return err
+	}
+	names, err := deployNames(ctx)
+	if err != nil {
+		return err
+	}
+	deployments, err := provider.ParseDeployments(ctx, p, names)
+	if err != nil {
+		return err
+	}
+
+	if len(deployments) == 1 {
+		if err := provider.DeployNode(p, deployments[0]); err != nil {
+			return err
+		}
+		return provider.OutputURL(deployments[0].Name)
+	}
+
+	ps := make([]provider.Provider, len(deployments))
+	for i := range ps {
+		ps[i] = p
+	}
+	return deployAll(ps, deployments, ctx.Int("concurrency"))
+}
+
+// deployAll deploys the darknodes with their providers in parallel, at most
+// concurrency at a time, and prints a summary of the results.
+func deployAll(ps []provider.Provider, deployments []provider.Deployment, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	color.Green("Deploying %v darknodes, %v at a time ...", len(deployments), concurrency)
+	color.Green("The terraform output of each darknode is written to its terraform.log")
+
+	results := make([]deployResult, len(deployments))
+	sem := make(chan struct{}, concurrency)
+	phi.ParForAll(deployments, func(i int) {
+		sem <- struct{}{}
+		defer func() { <-sem }()
+
+		d := deployments[i]
+		d.Quiet = true
+		results[i].deployment = d
+		results[i].err = provider.DeployNode(ps[i], d)
+		if results[i].err == nil {
+			results[i].url, results[i].err = util.RegisterUrl(d.Name)
+		}
+		if results[i].err != nil {
+			color.Red("[%v] cannot deploy the darknode: %v", d.Name, results[i].err)
+		} else {
+			color.Green("[%v] darknode is deployed", d.Name)
+		}
+	})
+
+	errs := make([]error, len(results))
+	fmt.Printf("\n%-20s | %-8s | %-15s | %s\n", "name", "status", "region", "register url / error")
+	for i, result := range results {
+		errs[i] = result.err
+		if result.err != nil {
+			fmt.Printf("%-20s | %-8s | %-15s | %v\n", result.deployment.Name, "failed", result.deployment.Region, result.err)
+		} else {
+			fmt.Printf("%-20s | %-8s | %-15s | %v\n", result.deployment.Name, "deployed", result.deployment.Region, result.url)
+		}
+	}
+	return util.HandleErrs(errs)
+}
+
+// deployNames returns the names of the darknodes to deploy, either the comma
+// separated names, or the given number of names starting with the prefix.
+// Numbers of existing darknodes are skipped.
+func deployNames(ctx *cli.Context) ([]string, error) {
+	count := ctx.Int("count")
+	if count == 0 {
+		var names []string
+		for _, name := range strings.Split(ctx.String("name"), ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, util.ErrEmptyName
+			}
+			if util.StringInSlice(name, names) {
+				return nil, ErrDuplicateName
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	}
+
+	if ctx.String("name") != "" {
+		return nil, ErrNameAndCount
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("invalid number of darknodes = %v", count)
+	}
+	prefix := strings.TrimSpace(ctx.String("name-prefix"))
+	if prefix == "" {
+		return nil, ErrEmptyNamePrefix
+	}
+	names := make([]string, 0, count)
+	for i := 1; len(names) < count; i++ {
+		name := fmt.Sprintf("%v%v", prefix, i)
+		if _, err := os.Stat(util.NodePath(name)); err == nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -46,57 +46,82 @@ func updateNode(ctx *cli.Context) error {
 	errs := make([]error, len(nodes))
 	phi.ParForAll(nodes, func(i int) {
 		errs[i] = updateSingleNode(nodes[i], version, force)
+		if errs[i] != nil && len(nodes) > 1 {
+			color.Red("[%v] %v", nodes[i], errs[i])
+		}
 	})
 	return util.HandleErrs(errs)
 }
 
+// updateSingleNode updates the darknode to the given version. It returns an
+// error if the update fails, or the version is lower than the current one and
+// force is not set.
 func updateSingleNode(name, ver string, force bool) error {
-	v := util.Version(name)
+	meta, err := util.ReadMetadata(name)
+	if err != nil {
+		return err
+	}
+	v := meta.DarknodeVersion
+	if v == "" {
+		v = util.Version(name)
+	}
 	curVersion, err := version.NewVersion(strings.TrimSpace(v))
 	if err != nil {
 		return err
 	}
 	newVersion, err := version.NewVersion(strings.TrimSpace(ver))
-	res := curVersion.Compare(newVersion)
-	switch res {
+	if err != nil {
+		return err
+	}
+	updated := "updated"
+	switch curVersion.Compare(newVersion) {
 	case 0:
 		if !force {
 			color.Green("darknode [%v] is running version [%v] already.", name, ver)
 			return nil
 		}
-		if err := update(name, ver); err != nil {
-			color.Red("cannot update darknode %v, error = %v", name, err)
-		} else {
-			color.Green("[%s] has been updated to version %v", name, ver)
-		}
 	case 1:
 		if !force {
-			color.Red("darknode [%v] is running with version %v, you cannot downgrade to a lower version %v", name, curVersion.String(), newVersion.String())
-			return nil
-		}
-		if err := update(name, ver); err != nil {
-			color.Red("cannot downgrade darknode %v, error = %v", name, err)
-		} else {
-			color.Green("[%s] has been downgraded to version %v", name, ver)
-		}
-	default:
-		if err := update(name, ver); err != nil {
-			color.Red("cannot update darknode %v, error = %v", name, err)
-		} else {
-			color.Green("[%s] has been updated to version %v", name, ver)
+			return fmt.Errorf("darknode [%v] is running with version %v, you cannot downgrade to a lower version %v", name, curVersion.String(), newVersion.String())
 		}
+		updated = "downgraded"
+	}
+	if err := update(name, ver); err != nil {
+		return fmt.Errorf("cannot update darknode [%v] to version %v, error = %v", name, ver, err)
 	}
+	color.Green("[%s] has been %v to version %v", name, updated, ver)
 	return nil
 }
 
+// update downloads the darknode binary of the given version on the darknode,
+// verifies it against the published checksum and restarts the darknode.
 func update(name, ver string) error {
-	url := fmt.Sprintf("https://www.github.com/renproject/darknode-release/releases/download/%v", ver)
-	script := fmt.Sprintf(`curl -sL %v/darknode > ~/.darknode/bin/darknode-new && 
-mv ~/.darknode/bin/darknode-new ~/.darknode/bin/darknode &&
-chmod +x ~/.darknode/bin/darknode && 
-echo %v > ~/.darknode/version &&
-systemctl --user restart darknode`, url, ver)
-	return util.RemoteRun(name, script)
+	checksum, err := util.ReleaseChecksum(ver)
+	if err != nil {
+		return err
+	}
+	if err := util.RemoteRun(name, updateScript(ver, checksum)); err != nil {
+		return err
+	}
+	return util.UpdateMetadata(name, func(meta *util.Metadata) {
+		meta.DarknodeVersion = ver
+	})
+}
+
+// updateScript returns the script which replaces the darknode binary with the
+// given version. The running binary is only replaced if the download matches
+// the checksum.
+func updateScript(ver, checksum string) string {
+	return fmt.Sprintf(`set -e
+curl -sSfL --retry 5 %v -o ~/.darknode/bin/darknode-new
+if ! echo "%v  $HOME/.darknode/bin/darknode-new" | sha256sum -c -; then
+  rm -f ~/.darknode/bin/darknode-new
+  exit 1
+fi
+mv ~/.darknode/bin/darknode-new ~/.darknode/bin/darknode
+chmod +x ~/.darknode/bin/darknode
+echo %v > ~/.darknode/version
+systemctl --user restart darknode`, util.ReleaseURL(ver), checksum, ver)
 }
 
 func validateVersion(version string) error {
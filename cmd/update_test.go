@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateScript(t *testing.T) {
+	script := updateScript("0.4.0", "0123abcd")
+	check := strings.Index(script, `echo "0123abcd  $HOME/.darknode/bin/darknode-new" | sha256sum -c -`)
+	move := strings.Index(script, "mv ~/.darknode/bin/darknode-new ~/.darknode/bin/darknode")
+	if check < 0 || move < 0 || check > move {
+		t.Fatalf("the binary is not verified before it's replaced\n%v", script)
+	}
+
+	// A download which doesn't match the checksum never replaces the binary.
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum is not installed")
+	}
+	home, err := ioutil.TempDir("", "darknode-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	bin := filepath.Join(home, ".darknode", "bin")
+	if err := os.MkdirAll(bin, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(bin, "darknode"), []byte("old"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(bin, "darknode-new"), []byte("tampered"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	// Skip the download and run the rest of the script.
+	lines := strings.Split(script, "\n")
+	cmd := exec.Command("bash", "-c", strings.Join(append(lines[:1], lines[2:]...), "\n"))
+	cmd.Env = []string{"HOME=" + home, "PATH=" + os.Getenv("PATH")}
+	if output, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("script succeeded with a wrong checksum: %s", output)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(bin, "darknode"))
+	if err != nil || string(data) != "old" {
+		t.Errorf("binary has been replaced, %s, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(bin, "darknode-new")); !os.IsNotExist(err) {
+		t.Errorf("download is not removed, err = %v", err)
+	}
+}
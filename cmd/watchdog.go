@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/renproject/darknode-cli/cmd/provider"
+	"github.com/renproject/darknode-cli/util"
+	"github.com/renproject/phi"
+	"github.com/urfave/cli"
+)
+
+// onceRetryInterval is the time between the checks of an unreachable darknode
+// when the watchdog only runs once.
+const onceRetryInterval = 30 * time.Second
+
+// watchdog keeps checking the darknodes deployed on spot instances and
+// redeploys the ones whose instance has been reclaimed by the cloud provider.
+// A darknode is only checked with the provider after it has failed the given
+// number of consecutive checks, so a network blip never triggers a redeploy.
+func watchdog(ctx *cli.Context) error {
+	name := ctx.Args().First()
+	tags := ctx.String("tags")
+	interval := ctx.Duration("interval")
+	threshold := ctx.Int("failures")
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	// Nobody is there to enter the MFA code when the credentials expire.
+	provider.RunUnattended()
+
+	failures := &failureCounter{counts: map[string]int{}}
+	providers := &providerCache{ctx: ctx, providers: map[string]provider.Provider{}}
+	for round := 1; ; round++ {
+		nodes, err := spotNodes(name, tags)
+		if err != nil {
+			return err
+		}
+
+		// Make sure the credentials work before watching the darknodes.
+		if round == 1 {
+			for _, node := range nodes {
+				if _, err := providers.get(node); err != nil {
+					return fmt.Errorf("cannot watch [%v], err = %v", node, err)
+				}
+			}
+		}
+
+		errs := make([]error, len(nodes))
+		phi.ParForAll(nodes, func(i int) {
+			errs[i] = watch(nodes[i], providers, failures, threshold)
+		})
+		if ctx.Bool("once") {
+			if !failures.any() || round >= threshold {
+				return util.HandleErrs(errs)
+			}
+			time.Sleep(onceRetryInterval)
+			continue
+		}
+		time.Sleep(interval)
+	}
+}
+
+// failureCounter counts the consecutive failed checks of each darknode.
+type failureCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// fail records a failed check and returns the number of consecutive ones.
+func (counter *failureCounter) fail(name string) int {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	counter.counts[name]++
+	return counter.counts[name]
+}
+
+// reset clears the failed checks of the darknode.
+func (counter *failureCounter) reset(name string) {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	delete(counter.counts, name)
+}
+
+// any returns whether any darknode has failed its last check.
+func (counter *failureCounter) any() bool {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	return len(counter.counts) > 0
+}
+
+// providerCache creates the provider of each cloud once per run, so the
+// credentials are resolved once and refreshed by the provider when they expire.
+type providerCache struct {
+	mu        sync.Mutex
+	ctx       *cli.Context
+	providers map[string]provider.Provider
+}
+
+// get returns the provider of the darknode.
+func (cache *providerCache) get(name string) (provider.Provider, error) {
+	providerName, err := provider.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if p, ok := cache.providers[providerName]; ok {
+		return p, nil
+	}
+	p, err := provider.NewProvider(cache.ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+	cache.providers[providerName] = p
+	return p, nil
+}
+
+// spotNodes returns the darknodes deployed on spot instances with the given
+// name or tags, or all of them if neither is given.
+func spotNodes(name, tags string) ([]string, error) {
+	var nodes []string
+	if name == "" && tags == "" {
+		files, err := ioutil.ReadDir(filepath.Join(util.Directory, "darknodes"))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				nodes = append(nodes, f.Name())
+			}
+		}
+	} else {
+		var err error
+		nodes, err = util.ParseNodesFromNameAndTags(name, tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spot := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if isSpot(node) {
+			spot = append(spot, node)
+		} else if name != "" {
+			return nil, fmt.Errorf("darknode [%v] is not deployed on a spot instance", name)
+		}
+	}
+	return spot, nil
+}
+
+// isSpot returns whether the darknode is deployed on a spot instance.
+func isSpot(name string) bool {
+	meta, err := util.ReadMetadata(name)
+	return err == nil && meta.Spot
+}
+
+// watch redeploys the darknode if it has failed enough consecutive checks and
+// the provider reports that its instance no longer exists.
+func watch(name string, providers *providerCache, failures *failureCounter, threshold int) error {
+	if _, err := util.RemoteOutput(name, "true"); err == nil {
+		failures.reset(name)
+		return nil
+	}
+	failed := failures.fail(name)
+	if failed < threshold {
+		color.Yellow("[%v] is not reachable (%v/%v failed checks)", name, failed, threshold)
+		return nil
+	}
+
+	color.Yellow("[%v] is not reachable after %v checks, checking the instance ...", name, failed)
+	p, err := providers.get(name)
+	if err != nil {
+		color.Red("[%v] cannot be checked: %v", name, err)
+		return err
+	}
+	redeployed, err := p.Recover(name)
+	if err != nil {
+		color.Red("[%v] failed to redeploy: %v", name, err)
+		return err
+	}
+	if redeployed {
+		failures.reset(name)
+		color.Green("[%v] has been redeployed with the same keystore.", name)
+	} else {
+		color.Yellow("[%v] instance still exists, it might be restarting.", name)
+	}
+	return nil
+}
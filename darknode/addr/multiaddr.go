@@ -89,7 +89,8 @@ func (multi MultiAddress) Generate(rand *rand.Rand, size int) reflect.Value {
 // NewMultiAddressFromString returns a new MultiAddress that uses the current
 // seconds since UNIX epoch as its nonce, but is unsigned. An error is returned
 // if the string is not in the form
-// `"/ip4/{ip-address}/tcp/{port}/ren/{base58-encoded-id}"`.
+// `"/ip4/{ip-address}/tcp/{port}/ren/{base58-encoded-id}"` or
+// `"/ip6/{ip-address}/tcp/{port}/ren/{base58-encoded-id}"`.
 func NewMultiAddressFromString(value string) (MultiAddress, error) {
 	multiAddr, err := multiaddr.NewMultiaddr(value)
 	if err != nil {
@@ -100,7 +101,7 @@ func NewMultiAddressFromString(value string) (MultiAddress, error) {
 	if err != nil {
 		return MultiAddress{}, err
 	}
-	ip4Addr, err := multiAddr.ValueForProtocol(IP4Code)
+	ipAddr, err := ipFromMultiaddr(multiAddr)
 	if err != nil {
 		return MultiAddress{}, err
 	}
@@ -108,7 +109,7 @@ func NewMultiAddressFromString(value string) (MultiAddress, error) {
 	if err != nil {
 		return MultiAddress{}, err
 	}
-	if _, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%v:%v", ip4Addr, port)); err != nil {
+	if _, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(ipAddr, port)); err != nil {
 		return MultiAddress{}, err
 	}
 
@@ -128,7 +129,7 @@ func NewSignedMultiAddressFromString(value, signature string) (MultiAddress, err
 	if err != nil {
 		return MultiAddress{}, err
 	}
-	ip4Addr, err := multiAddr.ValueForProtocol(IP4Code)
+	ipAddr, err := ipFromMultiaddr(multiAddr)
 	if err != nil {
 		return MultiAddress{}, err
 	}
@@ -136,7 +137,7 @@ func NewSignedMultiAddressFromString(value, signature string) (MultiAddress, err
 	if err != nil {
 		return MultiAddress{}, err
 	}
-	if _, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%v:%v", ip4Addr, port)); err != nil {
+	if _, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(ipAddr, port)); err != nil {
 		return MultiAddress{}, err
 	}
 
@@ -154,6 +155,15 @@ func NewSignedMultiAddressFromString(value, signature string) (MultiAddress, err
 	}, err
 }
 
+// ipFromMultiaddr returns the IPv4 address of the multi-address if it has one,
+// and the IPv6 address otherwise.
+func ipFromMultiaddr(multiAddr multiaddr.Multiaddr) (string, error) {
+	if ip4, err := multiAddr.ValueForProtocol(IP4Code); err == nil {
+		return ip4, nil
+	}
+	return multiAddr.ValueForProtocol(IP6Code)
+}
+
 func multiAddressFromArgs(value string, nonce uint64, signature [65]byte) (MultiAddress, error) {
 	multiAddress, err := NewMultiAddressFromString(value)
 	if err != nil {
@@ -194,6 +204,24 @@ func (multiAddr MultiAddress) IP4() string {
 	return ip4
 }
 
+func (multiAddr MultiAddress) IP6() string {
+	ip6, err := multiAddr.value.ValueForProtocol(IP6Code)
+	if err != nil {
+		panic(err)
+	}
+	return ip6
+}
+
+// IP returns the IP address of the multi-address, regardless of whether it is
+// an IPv4 or an IPv6 address.
+func (multiAddr MultiAddress) IP() string {
+	ip, err := ipFromMultiaddr(multiAddr.value)
+	if err != nil {
+		panic(err)
+	}
+	return ip
+}
+
 func (multiAddr MultiAddress) Port() int {
 	portString, err := multiAddr.value.ValueForProtocol(TCPCode)
 	if err != nil {
@@ -207,7 +235,7 @@ func (multiAddr MultiAddress) Port() int {
 }
 
 func (multiAddr MultiAddress) NetworkAddress() net.Addr {
-	ipAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%v:%v", multiAddr.IP4(), multiAddr.Port()))
+	ipAddr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(multiAddr.IP(), strconv.Itoa(multiAddr.Port())))
 	if err != nil {
 		panic(err)
 	}
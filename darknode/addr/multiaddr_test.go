@@ -0,0 +1,150 @@
+package addr
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto/secp256k1"
+)
+
+func TestMultiAddressRoundTrip(t *testing.T) {
+	key, err := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := FromPublicKey(key.PublicKey).ToBase58()
+
+	tests := []struct {
+		name  string
+		value string
+		ip    string
+	}{
+		{"ip4", fmt.Sprintf("/ip4/3.250.1.2/tcp/18514/ren/%v", id), "3.250.1.2"},
+		{"ip6", fmt.Sprintf("/ip6/2001:db8::1/tcp/18514/ren/%v", id), "2001:db8::1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			multi, err := NewMultiAddressFromString(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if multi.String() != test.value || multi.IP() != test.ip || multi.Port() != 18514 {
+				t.Fatalf("unexpected multi-address %v, ip = %v, port = %v", multi, multi.IP(), multi.Port())
+			}
+			if multi.ID().ToBase58() != id {
+				t.Fatalf("unexpected id %v", multi.ID())
+			}
+			if test.name == "ip6" && multi.IP6() != test.ip {
+				t.Fatalf("unexpected ip6 %v", multi.IP6())
+			}
+			if multi.Verify() {
+				t.Fatal("unsigned multi-address is verified")
+			}
+			if err := multi.Sign(key); err != nil {
+				t.Fatal(err)
+			}
+			if !multi.Verify() {
+				t.Fatal("signed multi-address cannot be verified")
+			}
+
+			// JSON
+			data, err := json.Marshal(&multi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fromJSON MultiAddress
+			if err := json.Unmarshal(data, &fromJSON); err != nil {
+				t.Fatal(err)
+			}
+			if !fromJSON.Equal(multi) || !fromJSON.Verify() {
+				t.Errorf("multi-address changed after the JSON round trip, got %v", fromJSON)
+			}
+
+			// Binary
+			data, err = multi.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fromBinary MultiAddress
+			if err := fromBinary.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if !fromBinary.Equal(multi) || !fromBinary.Verify() {
+				t.Errorf("multi-address changed after the binary round trip, got %v", fromBinary)
+			}
+
+			// Signed string
+			signed, err := NewSignedMultiAddressFromString(test.value, base64.StdEncoding.EncodeToString(multi.signature[:]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !signed.Verify() || signed.IP() != test.ip {
+				t.Errorf("signed multi-address cannot be verified, got %v", signed)
+			}
+		})
+	}
+}
+
+func TestMultiAddressSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := FromPublicKey(key.PublicKey).ToBase58()
+
+	for _, value := range []string{
+		fmt.Sprintf("/ip4/3.250.1.2/tcp/18514/ren/%v", id),
+		fmt.Sprintf("/ip6/2001:db8::1/tcp/18514/ren/%v", id),
+	} {
+		// Signed by a key which does not match the ID.
+		multi, err := NewMultiAddressFromString(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := multi.Sign(other); err != nil {
+			t.Fatal(err)
+		}
+		if multi.Verify() {
+			t.Errorf("%v is verified with the signature of another key", value)
+		}
+
+		// The signature does not cover another address.
+		if err := multi.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		moved, err := NewSignedMultiAddressFromString(fmt.Sprintf("/ip4/3.250.1.3/tcp/18514/ren/%v", id), base64.StdEncoding.EncodeToString(multi.signature[:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moved.Verify() {
+			t.Errorf("signature of %v is valid for another address", value)
+		}
+	}
+}
+
+func TestMultiAddressInvalid(t *testing.T) {
+	key, err := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := FromPublicKey(key.PublicKey).ToBase58()
+
+	for _, value := range []string{
+		"/ip4/3.250.1.2/tcp/18514",
+		fmt.Sprintf("/tcp/18514/ren/%v", id),
+		fmt.Sprintf("/ip6/not-an-ip/tcp/18514/ren/%v", id),
+		fmt.Sprintf("/ip6/2001:db8::1/ren/%v", id),
+	} {
+		if _, err := NewMultiAddressFromString(value); err == nil {
+			t.Errorf("%v should be rejected", value)
+		}
+	}
+}
@@ -3,7 +3,8 @@ module github.com/renproject/darknode-cli
 go 1.13
 
 require (
-	github.com/aws/aws-sdk-go v1.25.19
+	github.com/ProtonMail/go-crypto v1.0.0
+	github.com/aws/aws-sdk-go v1.44.300
 	github.com/btcsuite/btcd v0.20.1-beta
 	github.com/ethereum/go-ethereum v1.9.6
 	github.com/fatih/color v1.7.0
@@ -19,7 +20,8 @@ require (
 	github.com/sirupsen/logrus v1.4.2
 	github.com/syndtr/goleveldb v1.0.1-0.20190923125748-758128399b1d // indirect
 	github.com/urfave/cli v1.22.1
-	golang.org/x/crypto v0.0.0-20200709230013-948cd5f35899
+	golang.org/x/crypto v0.7.0
 	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
 	google.golang.org/api v0.13.0
+	gopkg.in/yaml.v2 v2.4.0
 )
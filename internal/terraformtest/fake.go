@@ -0,0 +1,91 @@
+// Package terraformtest provides an in-memory Terraform for testing the code
+// which runs terraform for darknodes.
+package terraformtest
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/renproject/darknode-cli/util"
+)
+
+// Fake is an in-memory util.Terraform. It records the commands it's asked to
+// run, and returns the configured outputs and errors instead of running
+// terraform. Replace util.NewTerraform to use it:
+//
+//	fake := terraformtest.NewFake(util.Outputs{"provider": "aws", "ip": "1.2.3.4"})
+//	util.NewTerraform = fake.New
+type Fake struct {
+	mu sync.Mutex
+
+	// Commands are the commands which have been run, e.g. "init -upgrade".
+	Commands []string
+
+	// Values are returned by Outputs.
+	Values util.Outputs
+
+	// Changes are returned by Plan.
+	Changes []util.ResourceChange
+
+	// Errs are returned by the command with the same name, e.g. "apply".
+	Errs map[string]error
+}
+
+// NewFake returns a Fake with the given outputs.
+func NewFake(outputs util.Outputs) *Fake {
+	return &Fake{
+		Values: outputs,
+		Errs:   map[string]error{},
+	}
+}
+
+// New has the same signature as NewTerraform, all darknodes share the fake.
+func (fake *Fake) New(name string, env []string) util.Terraform {
+	return fake
+}
+
+func (fake *Fake) Init(args ...string) error {
+	return fake.record("init", args...)
+}
+
+func (fake *Fake) Plan() ([]util.ResourceChange, error) {
+	if err := fake.record("plan"); err != nil {
+		return nil, err
+	}
+	return fake.Changes, nil
+}
+
+func (fake *Fake) Apply() error {
+	return fake.record("apply")
+}
+
+func (fake *Fake) Destroy() error {
+	return fake.record("destroy")
+}
+
+func (fake *Fake) Outputs() (util.Outputs, error) {
+	if err := fake.record("output"); err != nil {
+		return nil, err
+	}
+	return fake.Values, nil
+}
+
+func (fake *Fake) Run(args ...string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return fake.record(args[0], args[1:]...)
+}
+
+func (fake *Fake) Quiet() util.Terraform {
+	return fake
+}
+
+// record saves the command and returns the error configured for it.
+func (fake *Fake) record(command string, args ...string) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.Commands = append(fake.Commands, strings.TrimSpace(command+" "+strings.Join(args, " ")))
+	return fake.Errs[command]
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/renproject/darknode-cli/darknode"
+	"gopkg.in/yaml.v2"
+)
+
+// Fleet is the desired state of all the darknodes, described in a YAML or JSON
+// file.
+type Fleet struct {
+	Nodes []FleetNode `json:"nodes" yaml:"nodes"`
+}
+
+// FleetNode is the desired state of a single darknode. The version is the
+// darknode release it should run, the latest release is used for new darknodes
+// if it's empty. The region is picked randomly if it's empty. The other options
+// are the same as the ones of the `up` command, SSH is open to anywhere if no
+// CIDR ranges are given.
+type FleetNode struct {
+	Name     string   `json:"name" yaml:"name"`
+	Provider string   `json:"provider" yaml:"provider"`
+	Region   string   `json:"region" yaml:"region"`
+	Instance string   `json:"instance" yaml:"instance"`
+	Network  string   `json:"network" yaml:"network"`
+	Tags     []string `json:"tags" yaml:"tags"`
+	Version  string   `json:"version" yaml:"version"`
+	Image    string   `json:"image" yaml:"image"`
+	IPv6     bool     `json:"ipv6" yaml:"ipv6"`
+	StaticIP bool     `json:"staticIP" yaml:"staticIP"`
+	Spot     bool     `json:"spot" yaml:"spot"`
+	SSHCIDRs []string `json:"sshCidrs" yaml:"sshCidrs"`
+}
+
+// ReadFleet reads the fleet file at the given path. Files with the `.json`
+// extension are parsed as JSON, others as YAML.
+func ReadFleet(path string) (Fleet, error) {
+	var fleet Fleet
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fleet, err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &fleet)
+	} else {
+		err = yaml.UnmarshalStrict(data, &fleet)
+	}
+	if err != nil {
+		return fleet, fmt.Errorf("cannot parse %v, err = %v", path, err)
+	}
+	return fleet, fleet.validate()
+}
+
+// validate checks the names are unique, and normalises the network and tags of
+// each darknode.
+func (fleet *Fleet) validate() error {
+	names := map[string]bool{}
+	for i := range fleet.Nodes {
+		node := &fleet.Nodes[i]
+		node.Name = strings.TrimSpace(node.Name)
+		if node.Name == "" {
+			return ErrEmptyName
+		}
+		if names[node.Name] {
+			return fmt.Errorf("darknode [%v] is defined more than once", node.Name)
+		}
+		names[node.Name] = true
+
+		if node.Provider == "" {
+			return fmt.Errorf("darknode [%v] has no provider", node.Name)
+		}
+		if node.Network == "" {
+			node.Network = string(darknode.Mainnet)
+		}
+		network, err := darknode.NewNetwork(node.Network)
+		if err != nil {
+			return fmt.Errorf("darknode [%v] has an invalid network, err = %v", node.Name, err)
+		}
+		node.Network = string(network)
+		tags, err := ParseTags(strings.Join(node.Tags, ","))
+		if err != nil {
+			return fmt.Errorf("darknode [%v] has %v", node.Name, err)
+		}
+		node.Tags = tags
+		node.Region = strings.ToLower(strings.TrimSpace(node.Region))
+		node.Instance = strings.ToLower(strings.TrimSpace(node.Instance))
+		node.Version = strings.TrimSpace(node.Version)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/renproject/darknode-cli/darknode"
+)
+
+// MetadataVersion is the version of the format of the `node.json` file. It
+// should be increased whenever the format changes in a way which needs a
+// migration.
+const MetadataVersion = 1
+
+// Metadata are the facts about a darknode which are kept in the `node.json`
+// file in the node directory, so they can be read without running terraform or
+// connecting to the darknode.
+type Metadata struct {
+	Version         int              `json:"version"`
+	Name            string           `json:"name"`
+	Network         darknode.Network `json:"network"`
+	Provider        string           `json:"provider"`
+	Region          string           `json:"region"`
+	Instance        string           `json:"instance"`
+	IP              string           `json:"ip"`
+	SSHPort         int              `json:"sshPort,omitempty"`
+	Tags            []string         `json:"tags"`
+	Image           string           `json:"image"`
+	IPv6            bool             `json:"ipv6"`
+	StaticIP        bool             `json:"staticIP"`
+	Spot            bool             `json:"spot"`
+	DarknodeVersion string           `json:"darknodeVersion"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	UpdatedAt       time.Time        `json:"updatedAt"`
+}
+
+// MetadataPath returns the path of the `node.json` file of the darknode.
+func MetadataPath(name string) string {
+	return filepath.Join(NodePath(name), "node.json")
+}
+
+// CreateLegacyMetadata creates the metadata of a darknode deployed by an older
+// version of the CLI and returns whether it has been created. It's set by the
+// provider package, which knows how to read the terraform outputs.
+var CreateLegacyMetadata func(name string) (bool, error)
+
+// creatingMetadata has the names of the darknodes whose metadata is being
+// created, since creating it reads the metadata again.
+var creatingMetadata sync.Map
+
+// ReadMetadata reads the metadata of the darknode with given name. The metadata
+// of darknodes deployed by an older version of the CLI is created on the first
+// read, so they keep working after the CLI is upgraded.
+func ReadMetadata(name string) (Metadata, error) {
+	var meta Metadata
+	data, err := ioutil.ReadFile(MetadataPath(name))
+	if os.IsNotExist(err) && CreateLegacyMetadata != nil {
+		if _, creating := creatingMetadata.LoadOrStore(name, true); !creating {
+			_, createErr := CreateLegacyMetadata(name)
+			creatingMetadata.Delete(name)
+			if createErr != nil {
+				return meta, fmt.Errorf("cannot create the metadata of darknode [%v] deployed by an older version of the CLI, err = %v, please check the node directory and run `darknode migrate`", name, createErr)
+			}
+			data, err = ioutil.ReadFile(MetadataPath(name))
+		}
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, fmt.Errorf("darknode [%v] was deployed by an older version of the CLI, please run `darknode migrate` first", name)
+		}
+		return meta, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("cannot parse %v, err = %v", MetadataPath(name), err)
+	}
+	if meta.Version > MetadataVersion {
+		return meta, fmt.Errorf("%v is written by a newer version of the CLI, please update the CLI", MetadataPath(name))
+	}
+	return meta, nil
+}
+
+// WriteMetadata writes the metadata to the `node.json` file of the darknode.
+// The file is replaced atomically, so it's never left half written.
+func WriteMetadata(meta Metadata) error {
+	meta.Version = MetadataVersion
+	meta.UpdatedAt = time.Now().UTC()
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = meta.UpdatedAt
+	}
+	if meta.Tags == nil {
+		meta.Tags = []string{}
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := MetadataPath(meta.Name) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, MetadataPath(meta.Name))
+}
+
+// UpdateMetadata applies the changes to the metadata of the darknode and saves
+// it.
+func UpdateMetadata(name string, update func(meta *Metadata)) error {
+	meta, err := ReadMetadata(name)
+	if err != nil {
+		return err
+	}
+	update(&meta)
+	return WriteMetadata(meta)
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -44,7 +45,8 @@ func ParseNodesFromNameAndTags(name, tags string) ([]string, error) {
 	}
 }
 
-// ValidateNodeName checks if there exists a node with given name.
+// ValidateNodeName checks if there exists a node with given name and its
+// metadata can be read.
 func ValidateNodeName(name string) error {
 	files, err := ioutil.ReadDir(filepath.Join(Directory, "/darknodes"))
 	if err != nil {
@@ -52,7 +54,8 @@ func ValidateNodeName(name string) error {
 	}
 	for _, f := range files {
 		if f.Name() == name {
-			return nil
+			_, err := ReadMetadata(name)
+			return err
 		}
 	}
 	return fmt.Errorf("darknode [%v] not found", name)
@@ -74,15 +77,35 @@ func ID(name string) (addr.ID, error) {
 	return addr.FromPublicKey(config.Keystore.Ecdsa.PublicKey), nil
 }
 
-// IP gets the IP address of the node with given name.
+// IP gets the IP address of the node with given name from its metadata. The
+// terraform outputs are used if the metadata doesn't have the IP address yet,
+// e.g. when the node is still being deployed.
 func IP(name string) (string, error) {
 	if name == "" {
 		return "", ErrEmptyName
 	}
+	if meta, err := ReadMetadata(name); err == nil && meta.IP != "" {
+		return meta.IP, nil
+	}
 
-	cmd := fmt.Sprintf("cd %v && terraform output ip", NodePath(name))
-	ip, err := CommandOutput(cmd)
-	return strings.TrimSpace(ip), err
+	outputs, err := NewTerraform(name, nil).Outputs()
+	if err != nil {
+		return "", err
+	}
+	if ip := outputs.IP(); ip != "" {
+		return ip, nil
+	}
+	return "", fmt.Errorf("cannot find the IP address of [%v]", name)
+}
+
+// SSHPort returns the port of the SSH server of the node with given name. It's
+// only different from 22 for docker containers, whose SSH port is published on
+// the local machine.
+func SSHPort(name string) string {
+	if meta, err := ReadMetadata(name); err == nil && meta.SSHPort != 0 {
+		return strconv.Itoa(meta.SSHPort)
+	}
+	return "22"
 }
 
 // Version gets the version of the software the darknode currently is running.
@@ -125,25 +148,29 @@ func RegisterUrl(name string) (string, error) {
 	return fmt.Sprintf("https://%v.renproject.io/darknode/%v?action=register&public_key=0x%s&name=%v", network, id.String(), pubKeyHex, name), nil
 }
 
-// GetNodesByTags return the names of the nodes which have the given tags.
+// GetNodesByTags return the names of the nodes which are selected by the given
+// tag selector, see TagSelector for the syntax.
 func GetNodesByTags(tags string) ([]string, error) {
+	selector, err := ParseTagSelector(tags)
+	if err != nil {
+		return nil, err
+	}
 	files, err := ioutil.ReadDir(filepath.Join(Directory, "/darknodes"))
 	if err != nil {
 		return nil, err
 	}
 	nodes := make([]string, 0)
 	for _, f := range files {
-		path := filepath.Join(Directory, "darknodes", f.Name(), "tags.out")
-		tagFile, err := ioutil.ReadFile(path)
+		meta, err := ReadMetadata(f.Name())
 		if err != nil {
 			continue
 		}
-		if !ValidateTags(string(tagFile), tags) {
+		if !selector.Match(meta.Tags) {
 			continue
 		}
 
 		// Check if the node is fully deployed
-		if isDeployed(f.Name()) {
+		if meta.IP != "" {
 			nodes = append(nodes, f.Name())
 		}
 	}
@@ -154,16 +181,6 @@ func GetNodesByTags(tags string) ([]string, error) {
 	return nodes, nil
 }
 
-func ValidateTags(have, required string) bool {
-	tagsStr := strings.Split(strings.TrimSpace(required), ",")
-	for _, tag := range tagsStr {
-		if !strings.Contains(have, tag) {
-			return false
-		}
-	}
-	return true
-}
-
 // LatestStableRelease checks the darknode release repo and return the version
 // of the latest release.
 func LatestStableRelease() (string, error) {
@@ -218,8 +235,29 @@ func LatestStableRelease() (string, error) {
 	return latest.String(), nil
 }
 
-func isDeployed(name string) bool {
-	path := NodePath(name)
-	script := fmt.Sprintf("cd %v && terraform output ip", path)
-	return SilentRun("bash", "-c", script) == nil
+// ReleaseURL returns the download url of the darknode binary of the given
+// release.
+func ReleaseURL(version string) string {
+	return fmt.Sprintf("https://www.github.com/renproject/darknode-release/releases/download/%v/darknode", version)
+}
+
+// ReleaseChecksumsURL returns the download url of the SHA256SUMS file published
+// with the darknode release of the given version.
+func ReleaseChecksumsURL(version string) string {
+	return fmt.Sprintf("https://www.github.com/renproject/darknode-release/releases/download/%v/SHA256SUMS", version)
+}
+
+// ReleaseChecksum returns the SHA-256 checksum of the darknode binary published
+// with the release, so that the binary downloaded on the darknode can be
+// verified.
+func ReleaseChecksum(version string) (string, error) {
+	sums, err := download(ReleaseChecksumsURL(version))
+	if err != nil {
+		return "", fmt.Errorf("cannot get the checksums of darknode release [%v], err = %v", version, err)
+	}
+	checksum, ok := findChecksum(sums, "darknode")
+	if !ok {
+		return "", fmt.Errorf("darknode release [%v] has no published checksum of the darknode binary", version)
+	}
+	return checksum, nil
 }
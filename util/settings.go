@@ -0,0 +1,94 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Settings are the CLI-level settings shared by all darknodes. They're read
+// from the `settings.json` file under the .darknode directory.
+type Settings struct {
+	// Backend stores the terraform state of the darknodes remotely. The state
+	// is kept in the node directory if it's not set.
+	Backend *Backend `json:"backend,omitempty"`
+}
+
+// Backend is the terraform remote state backend of the darknodes.
+type Backend struct {
+	// Type of the backend, either "s3" or "gcs". Any S3-compatible storage can
+	// be used with the "s3" type by setting the Endpoint.
+	Type string `json:"type"`
+
+	// Bucket where the state is stored.
+	Bucket string `json:"bucket"`
+
+	// Prefix of the state of each darknode in the bucket. Defaults to
+	// "darknodes".
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region of the S3 bucket.
+	Region string `json:"region,omitempty"`
+
+	// Endpoint of an S3-compatible storage, e.g. a local MinIO server.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Profile is the AWS profile whose credentials are used to access the S3
+	// bucket, so that they can be different from the credentials used for
+	// deploying darknodes.
+	Profile string `json:"profile,omitempty"`
+
+	// DynamoDBTable is the DynamoDB table used for state locking. The state is
+	// locked with a lock file in the bucket if it's not set.
+	DynamoDBTable string `json:"dynamodb_table,omitempty"`
+
+	// Credentials is the path of the service account key used to access the
+	// GCS bucket.
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// SettingsPath returns the path of the settings file.
+func SettingsPath() string {
+	return filepath.Join(Directory, "settings.json")
+}
+
+// ReadSettings reads the settings from the settings file. Empty settings are
+// returned if the file doesn't exist.
+func ReadSettings() (Settings, error) {
+	var settings Settings
+	data, err := ioutil.ReadFile(SettingsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("cannot parse %v, err = %v", SettingsPath(), err)
+	}
+	if settings.Backend != nil {
+		if err := settings.Backend.validate(); err != nil {
+			return settings, fmt.Errorf("invalid backend in %v, err = %v", SettingsPath(), err)
+		}
+	}
+	return settings, nil
+}
+
+// validate checks the required fields of the backend and fills in the defaults.
+func (backend *Backend) validate() error {
+	if backend.Type != "s3" && backend.Type != "gcs" {
+		return fmt.Errorf("unknown backend type %q, only s3 and gcs are supported", backend.Type)
+	}
+	if backend.Bucket == "" {
+		return fmt.Errorf("bucket cannot be empty")
+	}
+	if backend.Type == "s3" && backend.Region == "" {
+		return fmt.Errorf("region of the s3 bucket cannot be empty")
+	}
+	if backend.Prefix == "" {
+		backend.Prefix = "darknodes"
+	}
+	return nil
+}
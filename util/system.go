@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -118,7 +119,7 @@ func connect(name, user string) (*ssh.Session, error) {
 	if err != nil {
 		return nil, err
 	}
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%v:22", ip), &config)
+	client, err := ssh.Dial("tcp", net.JoinHostPort(ip, SSHPort(name)), &config)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,150 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Regex for a valid tag. The characters used by the tag selectors are not
+// allowed.
+var regexTag = regexp.MustCompile(`^[\w.:/@+-]+$`)
+
+// ValidateTag checks if the tag can be used to identify groups of darknodes.
+func ValidateTag(tag string) error {
+	if !regexTag.MatchString(tag) {
+		return fmt.Errorf("invalid tag [%v], tags can only contain letters, digits and any of _.:/@+-", tag)
+	}
+	return nil
+}
+
+// regexInvalidTagChars matches the characters which are not allowed in tags.
+var regexInvalidTagChars = regexp.MustCompile(`[^\w.:/@+-]+`)
+
+// SanitizeTag replaces each run of characters which are not allowed in tags
+// with a dash, e.g. `eu team` becomes `eu-team`. It returns an empty string if
+// nothing is left of the tag.
+func SanitizeTag(tag string) string {
+	return strings.Trim(regexInvalidTagChars.ReplaceAllString(strings.TrimSpace(tag), "-"), "-")
+}
+
+// ParseTags parses the comma separated tags into a sorted set of tags. Empty
+// tags are ignored and duplicate tags are removed.
+func ParseTags(tags string) ([]string, error) {
+	set := map[string]struct{}{}
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if err := ValidateTag(tag); err != nil {
+			return nil, err
+		}
+		set[tag] = struct{}{}
+	}
+	return tagSet(set), nil
+}
+
+// AddTags returns the union of the two sets of tags.
+func AddTags(tags, added []string) []string {
+	set := map[string]struct{}{}
+	for _, tag := range tags {
+		set[tag] = struct{}{}
+	}
+	for _, tag := range added {
+		set[tag] = struct{}{}
+	}
+	return tagSet(set)
+}
+
+// RemoveTags returns the tags which are not in the removed ones.
+func RemoveTags(tags, removed []string) []string {
+	set := map[string]struct{}{}
+	for _, tag := range tags {
+		set[tag] = struct{}{}
+	}
+	for _, tag := range removed {
+		delete(set, tag)
+	}
+	return tagSet(set)
+}
+
+// HasTag checks if the tag is in the tags.
+func HasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func tagSet(set map[string]struct{}) []string {
+	tags := make([]string, 0, len(set))
+	for tag := range set {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// TagSelector selects darknodes by their tags. Tags separated by commas must
+// all match, alternatives are separated by `|` and a tag prefixed with `!` must
+// not match. The comma binds tighter than `|`, so `mainnet,!canary|testnet`
+// selects the mainnet darknodes which are not canaries and all the testnet
+// darknodes.
+type TagSelector [][]tagTerm
+
+type tagTerm struct {
+	tag    string
+	negate bool
+}
+
+// ParseTagSelector parses the tag selector expression. An empty expression
+// selects all darknodes.
+func ParseTagSelector(expr string) (TagSelector, error) {
+	var selector TagSelector
+	if strings.TrimSpace(expr) == "" {
+		return selector, nil
+	}
+	for _, alternative := range strings.Split(expr, "|") {
+		var terms []tagTerm
+		for _, tag := range strings.Split(alternative, ",") {
+			tag = strings.TrimSpace(tag)
+			negate := strings.HasPrefix(tag, "!")
+			if negate {
+				tag = strings.TrimSpace(strings.TrimPrefix(tag, "!"))
+			}
+			if tag == "" {
+				return nil, fmt.Errorf("invalid tag selector [%v], empty tag", expr)
+			}
+			if err := ValidateTag(tag); err != nil {
+				return nil, fmt.Errorf("invalid tag selector [%v], %v", expr, err)
+			}
+			terms = append(terms, tagTerm{tag: tag, negate: negate})
+		}
+		selector = append(selector, terms)
+	}
+	return selector, nil
+}
+
+// Match checks if the darknode with the given tags is selected.
+func (selector TagSelector) Match(tags []string) bool {
+	if len(selector) == 0 {
+		return true
+	}
+	for _, terms := range selector {
+		matched := true
+		for _, term := range terms {
+			if HasTag(tags, term.tag) == term.negate {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		tags string
+		want []string
+		err  bool
+	}{
+		{"", []string{}, false},
+		{"mainnet", []string{"mainnet"}, false},
+		{" prod , eu,prod,, eu ", []string{"eu", "prod"}, false},
+		{"region:eu-west-1,team@ren", []string{"region:eu-west-1", "team@ren"}, false},
+		{"eu team", nil, true},
+		{"prod|canary", nil, true},
+		{"!canary", nil, true},
+	}
+	for _, test := range tests {
+		tags, err := ParseTags(test.tags)
+		if (err != nil) != test.err {
+			t.Errorf("ParseTags(%q): unexpected error %v", test.tags, err)
+			continue
+		}
+		if !test.err && !reflect.DeepEqual(tags, test.want) {
+			t.Errorf("ParseTags(%q): expected %v, got %v", test.tags, test.want, tags)
+		}
+	}
+}
+
+func TestTagSelector(t *testing.T) {
+	prod := []string{"eu", "prod"}
+	preprod := []string{"eu", "preprod"}
+	canary := []string{"canary", "prod", "us"}
+	testnet := []string{"canary", "testnet"}
+
+	tests := []struct {
+		selector string
+		tags     []string
+		match    bool
+	}{
+		// Tags match exactly, not as substrings.
+		{"prod", prod, true},
+		{"prod", preprod, false},
+		{"pre", preprod, false},
+		{"", preprod, true},
+
+		// Commas are AND, `|` is OR, `!` is NOT.
+		{"prod,eu", prod, true},
+		{"prod,eu", canary, false},
+		{"eu|us", canary, true},
+		{"!canary", prod, true},
+		{"!canary", canary, false},
+		{"prod,!canary", canary, false},
+
+		// The comma binds tighter than `|`.
+		{"prod,!canary|testnet", prod, true},
+		{"prod,!canary|testnet", canary, false},
+		{"prod,!canary|testnet", testnet, true},
+		{"testnet|prod,us", canary, true},
+		{"testnet|prod,us", prod, false},
+		{" prod , ! canary ", prod, true},
+	}
+	for _, test := range tests {
+		selector, err := ParseTagSelector(test.selector)
+		if err != nil {
+			t.Errorf("ParseTagSelector(%q): unexpected error %v", test.selector, err)
+			continue
+		}
+		if selector.Match(test.tags) != test.match {
+			t.Errorf("%q matching %v: expected %v", test.selector, test.tags, test.match)
+		}
+	}
+
+	for _, invalid := range []string{"prod,", "|prod", "prod||eu", "!", "prod,!", "eu team", "prod&eu", "!!prod"} {
+		if _, err := ParseTagSelector(invalid); err == nil {
+			t.Errorf("ParseTagSelector(%q): expected an error", invalid)
+		}
+	}
+}
+
+func TestAddRemoveTags(t *testing.T) {
+	tags := AddTags([]string{"prod", "eu"}, []string{"eu", "canary"})
+	if want := []string{"canary", "eu", "prod"}; !reflect.DeepEqual(tags, want) {
+		t.Fatalf("expected %v, got %v", want, tags)
+	}
+	tags = RemoveTags(tags, []string{"canary", "missing"})
+	if want := []string{"eu", "prod"}; !reflect.DeepEqual(tags, want) {
+		t.Fatalf("expected %v, got %v", want, tags)
+	}
+}
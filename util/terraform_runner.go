@@ -0,0 +1,363 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInitFailed is returned when terraform fails to initialise the node
+	// directory.
+	ErrInitFailed = errors.New("terraform init failed")
+
+	// ErrPlanFailed is returned when terraform fails to compare the resources
+	// with the config.
+	ErrPlanFailed = errors.New("terraform plan failed")
+
+	// ErrApplyFailed is returned when terraform fails to apply the config.
+	ErrApplyFailed = errors.New("terraform apply failed")
+
+	// ErrDestroyFailed is returned when terraform fails to destroy the
+	// resources.
+	ErrDestroyFailed = errors.New("terraform destroy failed")
+
+	// ErrStateLocked is returned when the terraform state is locked by another
+	// operation, e.g. a teammate running a command against the same darknode.
+	ErrStateLocked = errors.New("terraform state is locked by another operation")
+
+	// ErrCommandFailed is returned when any other terraform command fails.
+	ErrCommandFailed = errors.New("terraform command failed")
+
+	// ErrAuthFailed is returned when the provider rejects the credentials, or
+	// they don't have the permissions needed.
+	ErrAuthFailed = errors.New("cloud provider rejected the credentials")
+
+	// ErrQuotaExceeded is returned when the provider refuses to create more
+	// resources of the account.
+	ErrQuotaExceeded = errors.New("cloud provider quota exceeded")
+
+	// ErrInvalidInstanceType is returned when the provider reports that the
+	// instance type is invalid or not available in the region.
+	ErrInvalidInstanceType = errors.New("instance type is invalid or not available")
+)
+
+// terraformErrors map the errors reported by terraform and the providers to
+// the kind of the error. They are checked in order, so quota errors which are
+// reported as forbidden are not taken as authentication errors.
+var terraformErrors = []struct {
+	pattern *regexp.Regexp
+	kind    error
+}{
+	{regexp.MustCompile(`Error acquiring the state lock`), ErrStateLocked},
+	{regexp.MustCompile(`(?i)LimitExceeded|QUOTA_EXCEEDED|Quota '[^']+' exceeded|droplet limit|resource_limit_exceeded|limit exceeded`), ErrQuotaExceeded},
+	{regexp.MustCompile(`(?i)InvalidInstanceType|instance type .*not supported|Unsupported: .*instance type|invalid size|size is not available|machineTypes/[^']*' was not found|Invalid value for field 'resource\.machineType'|server type .*not found|unsupported location for server type`), ErrInvalidInstanceType},
+	{regexp.MustCompile(`(?i)AuthFailure|UnauthorizedOperation|InvalidClientTokenId|SignatureDoesNotMatch|ExpiredToken|Unable to authenticate you|unauthorized|invalid_grant|could not find default credentials|Error 403`), ErrAuthFailed},
+}
+
+// TerraformError is returned when a terraform command fails. Err is one of the
+// errors above, so it can be checked with errors.Is.
+type TerraformError struct {
+	Name    string
+	Command string
+	Err     error
+	Detail  string
+	LogPath string
+}
+
+// Error implements the `error` interface.
+func (e *TerraformError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("[%v] %v: %v, see %v for details", e.Name, e.Err, e.Detail, e.LogPath)
+	}
+	return fmt.Sprintf("[%v] %v, see %v for details", e.Name, e.Err, e.LogPath)
+}
+
+// Unwrap returns the kind of the error.
+func (e *TerraformError) Unwrap() error {
+	return e.Err
+}
+
+// Outputs are the values of the terraform outputs of a darknode.
+type Outputs map[string]interface{}
+
+// String returns the output with the given name as a string, or an empty
+// string if there's no such output.
+func (outputs Outputs) String(name string) string {
+	value, ok := outputs[name]
+	if !ok || value == nil {
+		return ""
+	}
+	if str, ok := value.(string); ok {
+		return str
+	}
+	return fmt.Sprint(value)
+}
+
+// IP returns the IP address of the darknode. The IPv6 address is returned if
+// the darknode does not have an IPv4 address.
+func (outputs Outputs) IP() string {
+	if ip := outputs.String("ip"); ip != "" {
+		return ip
+	}
+	return outputs.String("ipv6")
+}
+
+// ResourceChange is a change terraform plans to make to a resource.
+type ResourceChange struct {
+	Address string   `json:"address"`
+	Type    string   `json:"type"`
+	Actions []string `json:"actions"`
+}
+
+// Creates returns whether the resource is created, either as a new resource or
+// to replace the existing one.
+func (change ResourceChange) Creates() bool {
+	return StringInSlice("create", change.Actions)
+}
+
+// Deletes returns whether the resource is destroyed, either to be removed or
+// to be replaced.
+func (change ResourceChange) Deletes() bool {
+	return StringInSlice("delete", change.Actions)
+}
+
+// Terraform runs terraform commands against the config of a darknode.
+type Terraform interface {
+	// Init initialises the node directory with the given extra arguments.
+	Init(args ...string) error
+
+	// Plan returns the changes needed to make the resources match the config.
+	Plan() ([]ResourceChange, error)
+
+	// Apply creates or updates the resources to match the config.
+	Apply() error
+
+	// Destroy tears down all the resources.
+	Destroy() error
+
+	// Outputs returns all the outputs of the config.
+	Outputs() (Outputs, error)
+
+	// Run runs any other terraform command, its output only goes to the log.
+	Run(args ...string) error
+
+	// Quiet returns a Terraform which only writes the output of all commands
+	// to the log, e.g. when running terraform for many darknodes in parallel.
+	Quiet() Terraform
+}
+
+// NewTerraform returns the Terraform of the darknode with the given name. The
+// environment variables are passed to terraform on top of the ones of the CLI.
+// It can be replaced with a terraformtest.Fake in tests.
+var NewTerraform = func(name string, env []string) Terraform {
+	return terraformRunner{
+		name:   name,
+		dir:    NodePath(name),
+		env:    env,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+}
+
+// NewNetworkTerraform returns the Terraform of a network shared by darknodes,
+// e.g. the IPv6 VPC of an AWS region, whose config is kept in the networks
+// directory. It can be replaced with a terraformtest.Fake in tests.
+var NewNetworkTerraform = func(name string, env []string) Terraform {
+	return terraformRunner{
+		name:   name,
+		dir:    NetworkPath(name),
+		env:    env,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+}
+
+// NetworkPath returns the directory of the terraform config of the shared
+// network with the given name.
+func NetworkPath(name string) string {
+	return filepath.Join(Directory, "networks", name)
+}
+
+// TerraformLogPath returns the path of the file which keeps the output of all
+// terraform commands run for the darknode.
+func TerraformLogPath(name string) string {
+	return filepath.Join(NodePath(name), "terraform.log")
+}
+
+// terraformRunner runs the terraform binary managed by the CLI in the node
+// directory. The output of each command is appended to the log of the node and
+// shown to the user.
+type terraformRunner struct {
+	name   string
+	dir    string
+	env    []string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (tf terraformRunner) Init(args ...string) error {
+	args = append([]string{"init", "-input=false", "-no-color"}, args...)
+	return tf.run(ErrInitFailed, args...)
+}
+
+func (tf terraformRunner) Plan() ([]ResourceChange, error) {
+	planFile := "darknode.tfplan"
+	defer os.Remove(filepath.Join(tf.dir, planFile))
+	if err := tf.silent().run(ErrPlanFailed, "plan", "-input=false", "-no-color", "-out="+planFile); err != nil {
+		return nil, err
+	}
+	data, err := tf.output("show", "-json", planFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the plan of [%v]: %v", tf.name, err)
+	}
+
+	plan := struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Type    string `json:"type"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}{}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("cannot parse the plan of [%v]: %v", tf.name, err)
+	}
+	changes := make([]ResourceChange, 0)
+	for _, change := range plan.ResourceChanges {
+		actions := change.Change.Actions
+		if len(actions) == 1 && (actions[0] == "no-op" || actions[0] == "read") {
+			continue
+		}
+		changes = append(changes, ResourceChange{Address: change.Address, Type: change.Type, Actions: actions})
+	}
+	return changes, nil
+}
+
+func (tf terraformRunner) Apply() error {
+	return tf.run(ErrApplyFailed, "apply", "-auto-approve", "-input=false", "-no-color")
+}
+
+func (tf terraformRunner) Destroy() error {
+	return tf.run(ErrDestroyFailed, "destroy", "-auto-approve", "-input=false", "-no-color")
+}
+
+func (tf terraformRunner) Run(args ...string) error {
+	return tf.silent().run(ErrCommandFailed, args...)
+}
+
+func (tf terraformRunner) Quiet() Terraform {
+	tf.stdout = ioutil.Discard
+	tf.stderr = ioutil.Discard
+	return tf
+}
+
+func (tf terraformRunner) Outputs() (Outputs, error) {
+	data, err := tf.output("output", "-json")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the outputs of [%v]: %v", tf.name, err)
+	}
+
+	raw := map[string]struct {
+		Value interface{} `json:"value"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("cannot parse the outputs of [%v]: %v", tf.name, err)
+	}
+	outputs := Outputs{}
+	for name, output := range raw {
+		outputs[name] = output.Value
+	}
+	return outputs, nil
+}
+
+// logPath returns the path of the file which keeps the output of all terraform
+// commands run in the directory.
+func (tf terraformRunner) logPath() string {
+	return filepath.Join(tf.dir, "terraform.log")
+}
+
+// output runs the terraform command and returns what it writes to stdout. The
+// error contains what it writes to stderr if it fails.
+func (tf terraformRunner) output(args ...string) ([]byte, error) {
+	cmd := tf.command(args...)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.New(msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// silent returns a copy of the runner which only writes the output of the
+// commands to the log.
+func (tf terraformRunner) silent() terraformRunner {
+	tf.stdout = ioutil.Discard
+	return tf
+}
+
+// command returns the terraform command which runs in the node directory.
+func (tf terraformRunner) command(args ...string) *exec.Cmd {
+	cmd := exec.Command(TerraformPath(), args...)
+	cmd.Dir = tf.dir
+	cmd.Env = append(os.Environ(), tf.env...)
+	return cmd
+}
+
+// run runs the terraform command and returns a TerraformError of the given kind
+// if it fails.
+func (tf terraformRunner) run(kind error, args ...string) error {
+	logFile, err := os.OpenFile(tf.logPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	fmt.Fprintf(logFile, "\n=== %v terraform %v\n", time.Now().Format(time.RFC3339), strings.Join(args, " "))
+
+	stderr := new(bytes.Buffer)
+	cmd := tf.command(args...)
+	cmd.Stdout = io.MultiWriter(logFile, tf.stdout)
+	cmd.Stderr = io.MultiWriter(logFile, tf.stderr, stderr)
+	err = cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	for _, terraformErr := range terraformErrors {
+		if terraformErr.pattern.MatchString(stderr.String()) {
+			kind = terraformErr.kind
+			break
+		}
+	}
+	return &TerraformError{
+		Name:    tf.name,
+		Command: args[0],
+		Err:     kind,
+		Detail:  errorDetail(stderr.String()),
+		LogPath: tf.logPath(),
+	}
+}
+
+// errorDetail returns the summary of the first error reported by terraform.
+func errorDetail(stderr string) string {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "│╷╵ "))
+		if strings.HasPrefix(line, "Error: ") {
+			return strings.TrimPrefix(line, "Error: ")
+		}
+	}
+	return ""
+}
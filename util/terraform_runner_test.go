@@ -0,0 +1,190 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTerraformScript prints the given output and exits with the given code,
+// so the runner can be tested without terraform.
+const fakeTerraformScript = `#!/bin/sh
+printf '%s' "$FAKE_STDOUT"
+printf '%s' "$FAKE_STDERR" >&2
+exit "${FAKE_EXIT:-0}"
+`
+
+// setupRunner points the CLI directory to a temporary directory with a fake
+// terraform binary and a node directory, and returns the runner of the node.
+func setupRunner(t *testing.T, env ...string) (terraformRunner, *bytes.Buffer, *bytes.Buffer) {
+	dir, err := ioutil.TempDir("", "darknode-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDirectory := Directory
+	Directory = dir
+	t.Cleanup(func() {
+		Directory = oldDirectory
+		os.RemoveAll(dir)
+	})
+
+	if err := os.MkdirAll(filepath.Dir(TerraformPath()), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(TerraformPath(), []byte(fakeTerraformScript), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(NodePath("test"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	runner := NewTerraform("test", env).(terraformRunner)
+	runner.stdout, runner.stderr = stdout, stderr
+	return runner, stdout, stderr
+}
+
+func TestTerraformErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		kind   error
+	}{
+		{"generic", "Error: something went wrong", ErrApplyFailed},
+		{"state lock", "Error: Error acquiring the state lock\n\nLock Info: ...", ErrStateLocked},
+		{"aws auth", "Error: creating EC2 Instance: AuthFailure: AWS was not able to validate the provided access credentials", ErrAuthFailed},
+		{"do auth", "Error: Error creating droplet: POST https://api.digitalocean.com/v2/droplets: 401 Unable to authenticate you", ErrAuthFailed},
+		{"aws quota", "Error: creating EC2 Instance: VcpuLimitExceeded: You have requested more vCPU capacity than your current vCPU limit", ErrQuotaExceeded},
+		{"gcp quota", "Error: Error creating instance: googleapi: Error 403: Quota 'CPUS' exceeded.  Limit: 8.0 in region us-east1., quotaExceeded", ErrQuotaExceeded},
+		{"aws instance type", "Error: creating EC2 Instance: InvalidInstanceType: The instance type 't9.huge' does not exist", ErrInvalidInstanceType},
+		{"do size", "Error: Error resizing droplet: 422 You specified an invalid size for Droplet creation.", ErrInvalidInstanceType},
+		{"hetzner server type", "Error: server type xx99 not found", ErrInvalidInstanceType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runner, _, stderr := setupRunner(t, "FAKE_EXIT=1", "FAKE_STDERR="+test.stderr)
+			err := runner.Apply()
+
+			var tfErr *TerraformError
+			if !errors.As(err, &tfErr) {
+				t.Fatalf("expected a TerraformError, got %v", err)
+			}
+			if !errors.Is(err, test.kind) {
+				t.Fatalf("expected %v, got %v", test.kind, tfErr.Err)
+			}
+			if tfErr.Command != "apply" || tfErr.LogPath != TerraformLogPath("test") {
+				t.Fatalf("unexpected error %#v", tfErr)
+			}
+			if !strings.Contains(err.Error(), strings.TrimPrefix(strings.SplitN(test.stderr, "\n", 2)[0], "Error: ")) {
+				t.Fatalf("error does not contain the terraform error: %v", err)
+			}
+			if stderr.String() != test.stderr {
+				t.Fatalf("stderr is not shown to the user: %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestTerraformPlan(t *testing.T) {
+	plan := `{"resource_changes": [
+		{"address": "data.aws_ami.ubuntu", "type": "aws_ami", "change": {"actions": ["read"]}},
+		{"address": "aws_key_pair.darknode", "type": "aws_key_pair", "change": {"actions": ["no-op"]}},
+		{"address": "aws_instance.darknode", "type": "aws_instance", "change": {"actions": ["create"]}},
+		{"address": "null_resource.ufw", "type": "null_resource", "change": {"actions": ["delete", "create"]}}
+	]}`
+	runner, _, _ := setupRunner(t, "FAKE_STDOUT="+plan)
+	changes, err := runner.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %v", changes)
+	}
+	if instance := changes[0]; instance.Address != "aws_instance.darknode" || !instance.Creates() || instance.Deletes() {
+		t.Fatalf("unexpected change %v", instance)
+	}
+	if ufw := changes[1]; ufw.Type != "null_resource" || !ufw.Creates() || !ufw.Deletes() {
+		t.Fatalf("unexpected change %v", ufw)
+	}
+	if _, err := os.Stat(filepath.Join(NodePath("test"), "darknode.tfplan")); !os.IsNotExist(err) {
+		t.Fatalf("plan file is not removed, err = %v", err)
+	}
+
+	runner, _, _ = setupRunner(t, `FAKE_STDOUT={"format_version": "1.2"}`)
+	changes, err = runner.Plan()
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes, got %v, %v", changes, err)
+	}
+
+	runner, _, _ = setupRunner(t, "FAKE_EXIT=1", "FAKE_STDERR=Error: Error acquiring the state lock")
+	if _, err := runner.Plan(); !errors.Is(err, ErrStateLocked) {
+		t.Fatalf("expected %v, got %v", ErrStateLocked, err)
+	}
+}
+
+func TestTerraformLog(t *testing.T) {
+	runner, stdout, stderr := setupRunner(t, "FAKE_STDOUT=Apply complete!", "FAKE_STDERR=Warning: deprecated")
+	if err := runner.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(stdout.String(), "Apply complete!") != 2 || strings.Count(stderr.String(), "Warning: deprecated") != 2 {
+		t.Fatalf("output is not shown to the user: %q, %q", stdout.String(), stderr.String())
+	}
+
+	// Quiet commands only write to the log.
+	stdout.Reset()
+	stderr.Reset()
+	quiet := runner.Quiet()
+	if err := quiet.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	if err := quiet.Run("state", "list"); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Fatalf("quiet output is shown to the user: %q, %q", stdout.String(), stderr.String())
+	}
+
+	log, err := ioutil.ReadFile(TerraformLogPath("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{"terraform init -input=false", "terraform apply -auto-approve", "terraform destroy -auto-approve", "terraform state list"} {
+		if !strings.Contains(string(log), command) {
+			t.Fatalf("log does not contain %q:\n%v", command, string(log))
+		}
+	}
+	if strings.Count(string(log), "Apply complete!") != 4 || strings.Count(string(log), "Warning: deprecated") != 4 {
+		t.Fatalf("log does not contain the output of all commands:\n%v", string(log))
+	}
+	info, err := os.Stat(TerraformLogPath("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("log is readable by others: %v", info.Mode())
+	}
+}
+
+func TestTerraformOutputs(t *testing.T) {
+	runner, _, _ := setupRunner(t, `FAKE_STDOUT={"provider":{"value":"aws"},"ipv6":{"value":"::1"},"spot":{"value":true}}`)
+	outputs, err := runner.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs.String("provider") != "aws" || outputs.IP() != "::1" || outputs.String("spot") != "true" || outputs.String("missing") != "" {
+		t.Fatalf("unexpected outputs %v", outputs)
+	}
+
+	runner, _, _ = setupRunner(t, "FAKE_EXIT=1", "FAKE_STDERR=No outputs found")
+	if _, err := runner.Outputs(); err == nil || !strings.Contains(err.Error(), "No outputs found") {
+		t.Fatalf("expected the terraform error, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func TestHashicorpPublicKeyNotExpired(t *testing.T) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(hashicorpPublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expiry := keyExpiry(keyring); !expiry.After(time.Now()) {
+		t.Fatalf("the HashiCorp public key expired on %v", expiry)
+	}
+	if _, ok := keyring[0].SigningKey(time.Now()); !ok {
+		t.Fatal("the HashiCorp public key has no valid signing key")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	config := &packet.Config{
+		RSABits:         2048,
+		KeyLifetimeSecs: 24 * 60 * 60,
+		Time:            func() time.Time { return created },
+	}
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := new(bytes.Buffer)
+	w, err := armor.Encode(publicKey, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	data := []byte("abc  terraform_1.10.5_linux_amd64.zip\n")
+	sig := new(bytes.Buffer)
+	if err := openpgp.DetachSign(sig, entity, bytes.NewReader(data), config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifySignature(publicKey.String(), data, sig.Bytes(), time.Now()); err != nil {
+		t.Fatalf("valid signature is rejected: %v", err)
+	}
+	if err := verifySignature(publicKey.String(), []byte("tampered"), sig.Bytes(), time.Now()); err == nil {
+		t.Fatal("signature of tampered data is accepted")
+	}
+	err = verifySignature(publicKey.String(), data, sig.Bytes(), time.Now().Add(48*time.Hour))
+	if err == nil || !strings.Contains(err.Error(), "expired on") {
+		t.Fatalf("expected an expired key error, got %v", err)
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	sums := []byte("aaaa  darknode\nbbbb *darknode-arm64\ncccc  terraform_1.5.7_linux_amd64.zip\n")
+	for filename, expected := range map[string]string{
+		"darknode":                        "aaaa",
+		"darknode-arm64":                  "bbbb",
+		"terraform_1.5.7_linux_amd64.zip": "cccc",
+	} {
+		checksum, ok := findChecksum(sums, filename)
+		if !ok || checksum != expected {
+			t.Errorf("expected %v for %v, got %v", expected, filename, checksum)
+		}
+	}
+	if _, ok := findChecksum(sums, "dark"); ok {
+		t.Error("found the checksum of a missing file")
+	}
+}